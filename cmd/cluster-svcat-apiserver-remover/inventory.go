@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/openshift/cluster-svcat-apiserver-operator/pkg/remover"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+)
+
+// inventoryOptions holds the command line configuration of the inventory
+// subcommand.
+type inventoryOptions struct {
+	kubeconfig string
+	output     string
+	logFormat  string
+}
+
+func (o *inventoryOptions) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.kubeconfig, "kubeconfig", "", "path to a kubeconfig file, defaults to the in-cluster config, then $KUBECONFIG and ~/.kube/config")
+	fs.StringVarP(&o.output, "output", "o", "table", "output format, one of table or json")
+	fs.StringVar(&o.logFormat, "log-format", "text", "log format, one of text or json")
+}
+
+func (o *inventoryOptions) validate() error {
+	if o.output != "table" && o.output != "json" {
+		return fmt.Errorf("unsupported output format %q", o.output)
+	}
+	return setLogFormat(o.logFormat)
+}
+
+// runInventory reports what still depends on Service Catalog without changing
+// anything and returns the exit code.
+func runInventory(args []string) int {
+	options := &inventoryOptions{}
+	fs := flag.NewFlagSet("inventory", flag.ContinueOnError)
+	options.addFlags(fs)
+	if err := applyEnv(fs); err != nil {
+		log.Error(err)
+		return remover.ExitSetupFailure
+	}
+	if err := fs.Parse(args); err != nil {
+		return remover.ExitSetupFailure
+	}
+	if err := options.validate(); err != nil {
+		log.Error(err)
+		return remover.ExitSetupFailure
+	}
+
+	config, err := clientConfig(options.kubeconfig)
+	if err != nil {
+		log.Errorf("Failed to create LocalClientSet: %v", err)
+		return remover.ExitSetupFailure
+	}
+	clients, err := remover.NewClients(config)
+	if err != nil {
+		log.Error(err)
+		return remover.ExitSetupFailure
+	}
+
+	inventory, err := remover.New(clients, remover.Options{}).Inventory()
+	if err != nil {
+		log.Error(err)
+		return remover.ExitPartialFailure
+	}
+	if options.output == "json" {
+		err = inventory.WriteJSON(os.Stdout)
+	} else {
+		err = inventory.WriteTable(os.Stdout)
+	}
+	if err != nil {
+		log.Error(err)
+		return remover.ExitPartialFailure
+	}
+	return remover.ExitSuccess
+}
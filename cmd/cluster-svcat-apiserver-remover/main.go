@@ -1,131 +1,93 @@
 package main
 
 import (
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
-	operatorapiv1 "github.com/openshift/api/operator/v1"
-	configclient "github.com/openshift/client-go/config/clientset/versioned"
-	operatorclient "github.com/openshift/client-go/operator/clientset/versioned"
-	operatorv1 "github.com/openshift/client-go/operator/clientset/versioned/typed/operator/v1"
+	"github.com/openshift/cluster-svcat-apiserver-operator/pkg/remover"
 	log "github.com/sirupsen/logrus"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
+	flag "github.com/spf13/pflag"
 )
 
-var targetNamespaceName = "openshift-service-catalog-apiserver-operator"
-
-func createClientConfigFromFile(configPath string) (*rest.Config, error) {
-	clientConfig, err := clientcmd.LoadFromFile(configPath)
-	if err != nil {
-		return nil, err
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "inventory" {
+		os.Exit(runInventory(os.Args[2:]))
 	}
 
-	config, err := clientcmd.NewDefaultClientConfig(*clientConfig, &clientcmd.ConfigOverrides{}).ClientConfig()
-	if err != nil {
-		return nil, err
+	options := &commandOptions{Options: remover.Options{RetryBackoff: remover.DefaultRetryBackoff}}
+	options.addFlags(flag.CommandLine)
+	if err := applyEnv(flag.CommandLine); err != nil {
+		log.Error(err)
+		finish(remover.ExitSetupFailure)
 	}
-	return config, nil
-}
-
-func deleteTargetNamespace(kubeClient *kubernetes.Clientset, target string) {
-	log.Infof("Removing target namespace %s", target)
-	if err := kubeClient.CoreV1().Namespaces().Delete(target, nil); err != nil && !apierrors.IsNotFound(err) {
-		log.Errorf("problem removing target namespace [%s] :  %v", target, err)
+	flag.Parse()
+	if err := options.validate(); err != nil {
+		log.Error(err)
+		finish(remover.ExitSetupFailure)
 	}
-}
 
-func deleteCustomResource(client operatorv1.OperatorV1Interface) {
-	log.Info("Removing the ServiceCatalogAPIServer CR")
-	err := client.ServiceCatalogAPIServers().Delete("cluster", &metav1.DeleteOptions{})
+	log.Info("Starting openshift-service-catalog-apiserver-remover job")
+
+	config, err := clientConfig(options.kubeconfig)
 	if err != nil {
-		log.Errorf("ServiceCatalogAPIServer cr deletion failed: %v", err)
-	} else {
-		log.Info("ServiceCatalogAPIServer cr removed successfully.")
+		log.Errorf("Failed to create LocalClientSet: %v", err)
+		finish(remover.ExitSetupFailure)
 	}
-}
 
-func deleteClusterOperator(clientConfig *rest.Config) {
-	configClient, err := configclient.NewForConfig(clientConfig)
+	options.applyTimeouts(config)
+
+	clients, err := remover.NewClients(config)
 	if err != nil {
-		log.Errorf("problem getting config client, error %v", err)
+		log.Error(err)
+		finish(remover.ExitSetupFailure)
 	}
 
-	log.Info("Removing the service-catalog-apiserver clusteroperator")
-	err = configClient.ConfigV1().ClusterOperators().Delete("service-catalog-apiserver", &metav1.DeleteOptions{})
-	if err != nil && !apierrors.IsNotFound(err) {
-		log.Errorf("problem removing cluster operator [service-catalog-apiserver] :  %v", err)
-	}
-}
+	recorder, stopEvents := remover.NewEventRecorder(clients.Kube, options.RemoverNamespace)
+	clients.Recorder = recorder
 
-func deleteClusterRolesAndBindings(kubeClient *kubernetes.Clientset) {
-	log.Info("Removing ClusterRoleBinding: openshift-service-catalog-apiserver-operator")
-	err := kubeClient.RbacV1().ClusterRoleBindings().Delete("openshift-service-catalog-apiserver-operator", &metav1.DeleteOptions{})
-	if err != nil && !apierrors.IsNotFound(err) {
-		log.Errorf("problem removing cluster role binding [openshift-service-catalog-apiserver-operator] :  %v", err)
+	r := remover.New(clients, options.Options)
+	if options.metricsAddress != "" {
+		serveMetrics(options.metricsAddress, r.Metrics())
 	}
-
-	log.Info("Removing ClusterRole: openshift-service-catalog-apiserver-operator")
-	err = kubeClient.RbacV1().ClusterRoles().Delete("openshift-service-catalog-apiserver-operator", &metav1.DeleteOptions{})
-	if err != nil && !apierrors.IsNotFound(err) {
-		log.Errorf("problem removing cluster role [openshift-service-catalog-apiserver-operator] :  %v", err)
+	stopOnSignal(r)
+	code := r.Run()
+	r.Results().Report(options.DryRun)
+	if options.metricsFile != "" {
+		if err := r.Metrics().WriteFile(options.metricsFile); err != nil {
+			log.Errorf("Failed to write metrics to %s: %v", options.metricsFile, err)
+		}
 	}
+	stopEvents()
+	finish(code)
 }
 
-func main() {
-	log.Info("Starting openshift-service-catalog-apiserver-remover job")
+// stopOnSignal stops the remover on SIGTERM or SIGINT, so the current step
+// finishes and the partial result is still reported.
+func stopOnSignal(r *remover.Remover) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		sig := <-signals
+		log.Warningf("Received %v", sig)
+		r.Stop()
+	}()
+}
 
-	clientConfig, err := rest.InClusterConfig()
-	if err != nil {
-		clientConfig, err = createClientConfigFromFile(homedir.HomeDir() + "/.kube/config")
-		if err != nil {
-			log.Error("Failed to create LocalClientSet")
-			panic(err.Error())
+// serveMetrics serves the metrics at /metrics in the background.
+func serveMetrics(address string, metrics *remover.Metrics) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	go func() {
+		if err := http.ListenAndServe(address, mux); err != nil {
+			log.Errorf("Failed to serve metrics on %s: %v", address, err)
 		}
-	}
-
-	kubeClient, err := kubernetes.NewForConfig(clientConfig)
-	if err != nil {
-		panic(err.Error())
-	}
-
-	operatorClient, err := operatorclient.NewForConfig(clientConfig)
-	if err != nil {
-		log.Errorf("problem getting operator client, error %v", err)
-	}
-	operatorConfigClient := operatorClient.OperatorV1()
-	operatorConfig, err := operatorConfigClient.ServiceCatalogAPIServers().Get("cluster", metav1.GetOptions{})
-	if apierrors.IsNotFound(err) {
-		log.Info("ServiceCatalogAPIServer cr has already been removed.")
-		deleteTargetNamespace(kubeClient, targetNamespaceName)
-		deleteClusterOperator(clientConfig)
-		deleteClusterRolesAndBindings(kubeClient)
-		os.Exit(0)
-	} else if err != nil {
-		log.Errorf("problem getting ServiceCatalogAPIServer CR, error %v", err)
-	}
+	}()
+}
 
-	// Handle the various ManagementStates
-	switch operatorConfig.Spec.ManagementState {
-	case operatorapiv1.Managed:
-		log.Warning("We found a cluster-svcat-apiserver-operator in Managed state. Aborting")
-	case operatorapiv1.Unmanaged:
-		log.Info("ServiceCatalogAPIServer managementState is 'Unmanaged'")
-		deleteTargetNamespace(kubeClient, targetNamespaceName)
-		deleteCustomResource(operatorConfigClient)
-		deleteClusterOperator(clientConfig)
-		deleteClusterRolesAndBindings(kubeClient)
-	case operatorapiv1.Removed:
-		log.Info("ServiceCatalogAPIServer managementState is 'Removed'")
-		deleteTargetNamespace(kubeClient, targetNamespaceName)
-		deleteCustomResource(operatorConfigClient)
-		deleteClusterOperator(clientConfig)
-		deleteClusterRolesAndBindings(kubeClient)
-	default:
-		log.Error("Unknown managementState")
-	}
-	log.Info("The openshift-service-catalog-apiserver-remover job, has finished.")
+// finish exits with the given code.
+func finish(code int) {
+	log.Infof("The openshift-service-catalog-apiserver-remover job, has finished with exit code %d.", code)
+	os.Exit(code)
 }
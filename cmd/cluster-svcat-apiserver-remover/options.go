@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/openshift/cluster-svcat-apiserver-operator/pkg/remover"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// defaultRequestTimeout bounds a single API request, so a call to an
+// unresponsive API server cannot hold the run past its deadline.
+const defaultRequestTimeout = 30 * time.Second
+
+// envPrefix is prepended to the upper-cased flag name, with dashes replaced by
+// underscores, to form the environment variable overriding a flag.
+const envPrefix = "SVCAT_REMOVER_"
+
+// commandOptions holds the command line configuration of the remover.
+type commandOptions struct {
+	remover.Options
+	kubeconfig     string
+	logFormat      string
+	metricsAddress string
+	metricsFile    string
+	requestTimeout time.Duration
+}
+
+// addFlags registers the remover flags on fs.
+func (o *commandOptions) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.kubeconfig, "kubeconfig", "", "path to a kubeconfig file, defaults to the in-cluster config, then $KUBECONFIG and ~/.kube/config")
+	fs.StringVar(&o.RemoverNamespace, "remover-namespace", remover.DefaultRemoverNamespace, "namespace the remover runs in and keeps its state in")
+	fs.BoolVar(&o.ResetCheckpoint, "reset-checkpoint", false, fmt.Sprintf("ignore the %s ConfigMap of earlier runs and run every selected step", remover.CheckpointName))
+	fs.DurationVar(&o.LeaseDuration, "lease-duration", remover.DefaultLeaseDuration, fmt.Sprintf("how long the %s lease keeps other runs out if the remover stops renewing it", remover.LeaseName))
+	fs.DurationVar(&o.LeaseWait, "lease-wait", 0, "how long to wait for another run to release the lease, 0 refuses to run while it is held")
+	fs.StringVar(&o.JobName, "job-name", remover.DefaultJobName, "name of the Job running the remover, events are recorded against it")
+	fs.StringVar(&o.BackupTo, "backup-to", remover.BackupToConfigMap, "where to back up Service Catalog resources before removal, one of none, stdout, file or configmap")
+	fs.StringVar(&o.BackupPath, "backup-path", "", "file to write the backup to when --backup-to=file, for example on a mounted PVC")
+	fs.StringVar(&o.BackupFormat, "backup-format", remover.BackupFormatYAML, "format of the backup archive, one of yaml or json")
+	fs.StringVar(&o.Namespace, "namespace", remover.DefaultNamespace, "operator namespace to remove")
+	fs.StringVar(&o.OperandNamespace, "operand-namespace", remover.DefaultOperandNamespace, "namespace of the Service Catalog API server to remove")
+	fs.StringVar(&o.LegacyNamespace, "legacy-namespace", remover.DefaultLegacyNamespace, "namespace older releases installed Service Catalog into")
+	fs.StringVar(&o.CustomResourceName, "cr-name", remover.DefaultCustomResourceName, "name of the ServiceCatalogAPIServer CR")
+	fs.StringVar(&o.ClusterOperatorName, "clusteroperator-name", remover.DefaultClusterOperatorName, "name of the ClusterOperator to remove")
+	fs.StringVar(&o.ClusterRoleName, "clusterrole-name", remover.DefaultClusterRoleName, "name of the operator ClusterRole and ClusterRoleBinding to remove")
+	fs.StringVar(&o.ControllerManagerName, "controller-manager-name", remover.DefaultControllerManagerName, "name of the ServiceCatalogControllerManager CR")
+	fs.StringVar(&o.ControllerManagerNamespace, "controller-manager-namespace", remover.DefaultControllerManagerNamespace, "namespace of the Service Catalog controller manager")
+	fs.DurationVar(&o.ControllerManagerTimeout, "controller-manager-timeout", remover.DefaultControllerManagerTimeout, "how long to wait for the Service Catalog controller manager to be removed, 0 disables waiting")
+	fs.DurationVar(&o.Timeout, "timeout", 0, "maximum duration of the run, 0 means no limit")
+	fs.DurationVar(&o.StepTimeout, "step-timeout", 0, "maximum duration of the waits of each step, 0 means no limit")
+	fs.DurationVar(&o.requestTimeout, "request-timeout", defaultRequestTimeout, "maximum duration of a single API request, 0 means no limit")
+	fs.BoolVar(&o.DryRun, "dry-run", false, "report the objects that would be removed and submit deletions with server-side dry-run")
+	fs.BoolVar(&o.Force, "force", false, fmt.Sprintf("remove Service Catalog even if ServiceInstances or ServiceBindings still exist, like the %s=true annotation on the CR", remover.ForceRemovalAnnotation))
+	fs.BoolVar(&o.StripFinalizers, "strip-finalizers", false, "remove the Service Catalog finalizer from ServiceInstances and ServiceBindings so their namespaces can be deleted")
+	fs.StringVar(&o.metricsAddress, "metrics-address", "", "address to serve Prometheus metrics on at /metrics while running, for example :8080, empty disables it")
+	fs.StringVar(&o.metricsFile, "metrics-file", "", "file to write Prometheus metrics to at exit, for the node exporter textfile collector or a Pushgateway")
+	fs.StringVar(&o.logFormat, "log-format", "text", "log format, one of text or json")
+	fs.StringVar(&o.LogLevel, "log-level", "", "log level, one of Normal, Debug, Trace or TraceAll, defaults to the operatorLogLevel of the ServiceCatalogAPIServer CR")
+	fs.StringSliceVar(&o.Steps, "steps", nil, fmt.Sprintf("comma separated steps to run, defaults to all of %v", remover.StepNames()))
+	fs.DurationVar(&o.NamespaceTimeout, "namespace-timeout", remover.DefaultNamespaceTimeout, "how long to wait for deleted namespaces to terminate, 0 disables waiting")
+	fs.IntVar(&o.RetryBackoff.Steps, "retry-attempts", remover.DefaultRetryBackoff.Steps, "maximum number of attempts for API calls failing with a transient error")
+}
+
+// envName returns the environment variable that overrides the named flag.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// applyEnv sets every flag with a matching environment variable. It must be
+// called before parsing so command line flags take precedence.
+func applyEnv(fs *flag.FlagSet) error {
+	var errs []string
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok {
+			return
+		}
+		var err error
+		if slice, ok := f.Value.(flag.SliceValue); ok {
+			// Replace keeps the flag unchanged so the command line replaces
+			// the environment value instead of appending to it.
+			err = slice.Replace(strings.Split(value, ","))
+		} else {
+			err = f.Value.Set(value)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", envName(f.Name), err))
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid environment: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// validate checks the options and applies the log format.
+func (o *commandOptions) validate() error {
+	if err := setLogFormat(o.logFormat); err != nil {
+		return err
+	}
+	if err := remover.ValidateLogLevel(o.LogLevel); err != nil {
+		return err
+	}
+	switch o.BackupTo {
+	case remover.BackupToNone, remover.BackupToStdout, remover.BackupToConfigMap:
+	case remover.BackupToFile:
+		if o.BackupPath == "" {
+			return fmt.Errorf("--backup-path is required with --backup-to=file")
+		}
+	default:
+		return fmt.Errorf("unsupported backup destination %q", o.BackupTo)
+	}
+	if o.BackupFormat != remover.BackupFormatYAML && o.BackupFormat != remover.BackupFormatJSON {
+		return fmt.Errorf("unsupported backup format %q", o.BackupFormat)
+	}
+	if o.RetryBackoff.Steps < 1 {
+		return fmt.Errorf("--retry-attempts must be at least 1")
+	}
+	if o.Timeout < 0 || o.StepTimeout < 0 || o.requestTimeout < 0 {
+		return fmt.Errorf("--timeout, --step-timeout and --request-timeout must not be negative")
+	}
+	return remover.ValidateSteps(o.Steps)
+}
+
+// setLogFormat configures the log formatter, format is text or json.
+func setLogFormat(format string) error {
+	switch format {
+	case "text":
+		log.SetFormatter(&log.TextFormatter{})
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("unsupported log format %q", format)
+	}
+	return nil
+}
+
+// applyTimeouts bounds every request made with the config by the request
+// timeout, and by the run timeout when shorter.
+func (o *commandOptions) applyTimeouts(config *rest.Config) {
+	config.Timeout = o.requestTimeout
+	if o.Timeout > 0 && (config.Timeout == 0 || o.Timeout < config.Timeout) {
+		config.Timeout = o.Timeout
+	}
+}
+
+// clientConfig returns the config to reach the cluster. Without an explicit
+// kubeconfig the in-cluster config is preferred, then the clientcmd loading
+// rules ($KUBECONFIG, ~/.kube/config).
+func clientConfig(kubeconfig string) (*rest.Config, error) {
+	if kubeconfig == "" && os.Getenv(clientcmd.RecommendedConfigPathEnvVar) == "" {
+		if config, err := rest.InClusterConfig(); err == nil {
+			return config, nil
+		}
+	}
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"k8s.io/client-go/rest"
+)
+
+func TestApplyTimeouts(t *testing.T) {
+	tests := []struct {
+		requestTimeout time.Duration
+		timeout        time.Duration
+		expected       time.Duration
+	}{
+		{requestTimeout: 30 * time.Second, expected: 30 * time.Second},
+		{requestTimeout: 30 * time.Second, timeout: time.Minute, expected: 30 * time.Second},
+		{requestTimeout: 30 * time.Second, timeout: 10 * time.Second, expected: 10 * time.Second},
+		{timeout: 10 * time.Second, expected: 10 * time.Second},
+	}
+	for _, tc := range tests {
+		o := &commandOptions{requestTimeout: tc.requestTimeout}
+		o.Timeout = tc.timeout
+		config := &rest.Config{}
+		o.applyTimeouts(config)
+		if config.Timeout != tc.expected {
+			t.Errorf("request timeout %v, timeout %v: expected %v, got %v", tc.requestTimeout, tc.timeout, tc.expected, config.Timeout)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	os.Setenv("SVCAT_REMOVER_NAMESPACE", "from-env")
+	os.Setenv("SVCAT_REMOVER_CR_NAME", "from-env")
+	os.Setenv("SVCAT_REMOVER_STEPS", "delete-clusteroperator")
+	os.Setenv("SVCAT_REMOVER_CLUSTERROLE_NAME", "from-env")
+	defer os.Unsetenv("SVCAT_REMOVER_NAMESPACE")
+	defer os.Unsetenv("SVCAT_REMOVER_CR_NAME")
+	defer os.Unsetenv("SVCAT_REMOVER_STEPS")
+	defer os.Unsetenv("SVCAT_REMOVER_CLUSTERROLE_NAME")
+
+	o := &commandOptions{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o.addFlags(fs)
+	if err := applyEnv(fs); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"--cr-name=from-flag", "--steps=delete-rbac,delete-apiservices"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if o.Namespace != "from-env" {
+		t.Errorf("expected namespace from the environment, got %q", o.Namespace)
+	}
+	if o.CustomResourceName != "from-flag" {
+		t.Errorf("expected the flag to override the environment, got %q", o.CustomResourceName)
+	}
+	if o.ClusterRoleName != "from-env" {
+		t.Errorf("expected cluster role name from the environment, got %q", o.ClusterRoleName)
+	}
+	if expected := []string{"delete-rbac", "delete-apiservices"}; !reflect.DeepEqual(o.Steps, expected) {
+		t.Errorf("expected steps %v, got %v", expected, o.Steps)
+	}
+	if err := o.validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateRetryAttempts(t *testing.T) {
+	for _, tc := range []struct {
+		attempts  string
+		expectErr bool
+	}{
+		{attempts: "1"},
+		{attempts: "5"},
+		{attempts: "0", expectErr: true},
+		{attempts: "-1", expectErr: true},
+	} {
+		o := &commandOptions{}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		o.addFlags(fs)
+		if err := fs.Parse([]string{"--retry-attempts=" + tc.attempts}); err != nil {
+			t.Fatal(err)
+		}
+		if err := o.validate(); (err != nil) != tc.expectErr {
+			t.Errorf("--retry-attempts=%s: expected error %v, got %v", tc.attempts, tc.expectErr, err)
+		}
+	}
+}
+
+func TestApplyEnvInvalid(t *testing.T) {
+	os.Setenv("SVCAT_REMOVER_DRY_RUN", "maybe")
+	defer os.Unsetenv("SVCAT_REMOVER_DRY_RUN")
+
+	o := &commandOptions{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o.addFlags(fs)
+	if err := applyEnv(fs); err == nil {
+		t.Error("expected an error for an invalid boolean")
+	}
+}
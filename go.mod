@@ -6,6 +6,9 @@ require (
 	github.com/openshift/api v0.0.0-20200217161739-c99157bc6492
 	github.com/openshift/client-go v0.0.0-20200116152001-92a2713fa240
 	github.com/sirupsen/logrus v1.4.2
+	github.com/spf13/pflag v1.0.5
+	k8s.io/api v0.17.2
 	k8s.io/apimachinery v0.17.3-beta.0
 	k8s.io/client-go v0.17.2
+	sigs.k8s.io/yaml v1.1.0
 )
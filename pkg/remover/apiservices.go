@@ -0,0 +1,184 @@
+package remover
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+var apiServicesResource = schema.GroupVersionResource{Group: "apiregistration.k8s.io", Version: "v1", Resource: "apiservices"}
+
+// apiServiceAvailable returns the status of the Available condition of an
+// APIService and its message.
+func apiServiceAvailable(apiService *unstructured.Unstructured) (string, string) {
+	conditions, _, _ := unstructured.NestedSlice(apiService.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Available" {
+			continue
+		}
+		status, _ := condition["status"].(string)
+		message, _ := condition["message"].(string)
+		return status, message
+	}
+	return "Unknown", ""
+}
+
+// reportBackingService logs whether the service an APIService delegates to is
+// still present and serving. Failing to look the service up is not an error,
+// the APIService is removed regardless.
+func (r *Remover) reportBackingService(apiService *unstructured.Unstructured) {
+	namespace, _, _ := unstructured.NestedString(apiService.Object, "spec", "service", "namespace")
+	name, _, _ := unstructured.NestedString(apiService.Object, "spec", "service", "name")
+	entry := log.WithFields(objectFields("APIService", "", apiService.GetName()))
+	if name == "" {
+		entry.Info("APIService is served locally")
+		return
+	}
+	entry = entry.WithField("service", namespace+"/"+name)
+
+	err := r.retry(fmt.Sprintf("get Service %s/%s", namespace, name), func() error {
+		_, err := r.clients.Kube.CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
+		return err
+	})
+	switch {
+	case apierrors.IsNotFound(err):
+		entry.Info("APIService points at a missing service")
+		return
+	case err != nil:
+		entry.WithError(err).Warning("problem getting the service of the APIService")
+		return
+	}
+
+	status, message := apiServiceAvailable(apiService)
+	if status == "True" {
+		entry.Warning("APIService is still served, it will stop serving once removed")
+	} else {
+		entry.WithFields(log.Fields{"available": status, "message": message}).Info("APIService is not available")
+	}
+}
+
+// serviceCatalogBackendGone returns true if the Service Catalog API is known to
+// be dead: every servicecatalog.k8s.io APIService points at a service that, or
+// whose namespace, no longer exists. An API server that is only restarting
+// does not count, so callers must not treat its objects as gone.
+func (r *Remover) serviceCatalogBackendGone() (bool, error) {
+	apiServices, err := r.serviceCatalogAPIServices()
+	if err != nil || len(apiServices) == 0 {
+		return false, err
+	}
+	for _, apiService := range apiServices {
+		namespace, _, _ := unstructured.NestedString(apiService.Object, "spec", "service", "namespace")
+		name, _, _ := unstructured.NestedString(apiService.Object, "spec", "service", "name")
+		if name == "" {
+			return false, nil
+		}
+		err := r.retry(fmt.Sprintf("get Service %s/%s", namespace, name), func() error {
+			_, err := r.clients.Kube.CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
+			return err
+		})
+		if err == nil {
+			return false, nil
+		} else if !apierrors.IsNotFound(err) {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// serviceCatalogAPIGone returns true if err reports the Service Catalog API as
+// unavailable and its backend is known to be gone, so there is nothing left to
+// read from it. Any other error, including an unavailable API whose backend
+// still exists, must be treated as a failure.
+func (r *Remover) serviceCatalogAPIGone(err error) bool {
+	if !apierrors.IsServiceUnavailable(err) {
+		return false
+	}
+	gone, lookupErr := r.serviceCatalogBackendGone()
+	if lookupErr != nil {
+		log.Warningf("problem checking the backend of the Service Catalog API :  %v", lookupErr)
+		return false
+	}
+	return gone
+}
+
+// serviceCatalogAPIServices returns the APIServices registered for the
+// servicecatalog.k8s.io group.
+func (r *Remover) serviceCatalogAPIServices() ([]unstructured.Unstructured, error) {
+	var apiServices *unstructured.UnstructuredList
+	err := r.retry("list APIServices", func() (err error) {
+		apiServices, err = r.clients.Dynamic.Resource(apiServicesResource).List(metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var matching []unstructured.Unstructured
+	for _, apiService := range apiServices.Items {
+		group, _, _ := unstructured.NestedString(apiService.Object, "spec", "group")
+		if group == serviceCatalogGroup {
+			matching = append(matching, apiService)
+		}
+	}
+	return matching, nil
+}
+
+// apiServicesStep removes the aggregated APIServices for the
+// servicecatalog.k8s.io group so discovery stops failing once the Service
+// Catalog API server is gone.
+type apiServicesStep struct {
+	r *Remover
+}
+
+func (s *apiServicesStep) Name() string {
+	return "delete-apiservices"
+}
+
+func (s *apiServicesStep) Precondition() (bool, error) {
+	return true, nil
+}
+
+func (s *apiServicesStep) Execute() error {
+	log.Infof("Removing APIServices for group %s", serviceCatalogGroup)
+	apiServices, err := s.r.serviceCatalogAPIServices()
+	if err != nil {
+		s.r.results.add("APIService", "", "", outcomeFailed, err.Error())
+		return fmt.Errorf("problem listing APIServices :  %v", err)
+	}
+
+	var errs []error
+	for i := range apiServices {
+		apiService := &apiServices[i]
+		if s.r.options.DryRun {
+			log.WithFields(objectFields("APIService", "", apiService.GetName())).Info("[dry-run] exists and would be deleted")
+		}
+		s.r.reportBackingService(apiService)
+
+		err := s.r.deleteObject("APIService", "", apiService.GetName(), func(options *metav1.DeleteOptions) error {
+			return s.r.clients.Dynamic.Resource(apiServicesResource).Delete(apiService.GetName(), options)
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("problem removing APIService [%s] :  %v", apiService.GetName(), err))
+			continue
+		}
+		s.r.reportDryRunResult("APIService", apiService.GetName(), err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// Verify checks that no servicecatalog.k8s.io APIService is left.
+func (s *apiServicesStep) Verify() error {
+	apiServices, err := s.r.serviceCatalogAPIServices()
+	if err != nil {
+		return fmt.Errorf("problem listing APIServices :  %v", err)
+	}
+	if len(apiServices) > 0 {
+		return fmt.Errorf("APIService [%s] is still present", apiServices[0].GetName())
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package remover
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// Backup destinations.
+const (
+	BackupToNone      = "none"
+	BackupToStdout    = "stdout"
+	BackupToFile      = "file"
+	BackupToConfigMap = "configmap"
+)
+
+// Backup archive formats.
+const (
+	BackupFormatYAML = "yaml"
+	BackupFormatJSON = "json"
+)
+
+// DefaultRemoverNamespace is the namespace the remover job runs in.
+const DefaultRemoverNamespace = "openshift-service-catalog-removed"
+
+const (
+	// backupLabel is set on every ConfigMap of a backup to the backup ID.
+	backupLabel = "servicecatalog.openshift.io/backup"
+	// backupChunkAnnotation and backupChunksAnnotation give the position of
+	// a ConfigMap in the backup and the number of ConfigMaps in it.
+	backupChunkAnnotation  = "servicecatalog.openshift.io/backup-chunk"
+	backupChunksAnnotation = "servicecatalog.openshift.io/backup-chunks"
+	// backupChunkSize keeps each ConfigMap well below the 1MiB object limit.
+	backupChunkSize = 900 * 1024
+)
+
+// backupStep writes every servicecatalog.k8s.io object to an archive before
+// anything is removed, so brokered services can be audited or migrated once
+// the Service Catalog API server is gone.
+type backupStep struct {
+	r         *Remover
+	resources []discoveredResource
+	// id identifies the ConfigMaps of this backup.
+	id string
+	// configMaps lists the ConfigMaps written by Execute.
+	configMaps []string
+}
+
+func (s *backupStep) Name() string {
+	return "backup"
+}
+
+// Required stops the pipeline if the backup could not be written, since the
+// objects become unrecoverable once the API server is removed.
+func (s *backupStep) Required() bool {
+	return true
+}
+
+// Precondition skips the step when backups are disabled or there is no
+// Service Catalog API to back up. An API that is unavailable while its backend
+// still exists fails the step, so nothing is deleted without a backup.
+func (s *backupStep) Precondition() (bool, error) {
+	if s.r.options.BackupTo == BackupToNone {
+		log.Info("Backup of Service Catalog resources is disabled")
+		return false, nil
+	}
+	resources, err := s.r.discoverGroupResources(serviceCatalogGroup)
+	if s.r.serviceCatalogAPIGone(err) {
+		log.Warningf("Service Catalog API is not served by any service, nothing can be backed up: %v", err)
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("problem discovering %s resources :  %v", serviceCatalogGroup, err)
+	}
+	if len(resources) == 0 {
+		log.Infof("API group %s is not served, nothing to back up", serviceCatalogGroup)
+		return false, nil
+	}
+	s.resources = resources
+	return true, nil
+}
+
+func (s *backupStep) Execute() error {
+	var documents [][]byte
+	for _, resource := range s.resources {
+		var list *unstructured.UnstructuredList
+		err := s.r.retry(fmt.Sprintf("list %s", resource.gvr.Resource), func() (err error) {
+			list, err = s.r.clients.Dynamic.Resource(resource.gvr).Namespace(metav1.NamespaceAll).List(metav1.ListOptions{})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("problem listing %s :  %v", resource.gvr.Resource, err)
+		}
+		log.Infof("Backing up %d %s", len(list.Items), resource.gvr.Resource)
+		for i := range list.Items {
+			document, err := s.encode(&list.Items[i])
+			if err != nil {
+				return fmt.Errorf("problem encoding %s [%s/%s] :  %v", resource.kind, list.Items[i].GetNamespace(), list.Items[i].GetName(), err)
+			}
+			documents = append(documents, document)
+		}
+	}
+
+	switch s.r.options.BackupTo {
+	case BackupToStdout:
+		return writeDocuments(os.Stdout, documents)
+	case BackupToFile:
+		return s.writeFile(documents)
+	case BackupToConfigMap:
+		return s.writeConfigMaps(documents)
+	default:
+		return fmt.Errorf("unsupported backup destination %q", s.r.options.BackupTo)
+	}
+}
+
+// Verify checks that the backup can be read back from its destination.
+func (s *backupStep) Verify() error {
+	switch s.r.options.BackupTo {
+	case BackupToFile:
+		_, err := os.Stat(s.r.options.BackupPath)
+		return err
+	case BackupToConfigMap:
+		var errs []error
+		for _, name := range s.configMaps {
+			errs = append(errs, s.r.retry(fmt.Sprintf("get ConfigMap %s", name), func() error {
+				_, err := s.r.clients.Kube.CoreV1().ConfigMaps(s.r.options.RemoverNamespace).Get(name, metav1.GetOptions{})
+				return err
+			}))
+		}
+		return utilerrors.NewAggregate(errs)
+	}
+	return nil
+}
+
+// encode returns a single document of the archive for the object.
+func (s *backupStep) encode(obj *unstructured.Unstructured) ([]byte, error) {
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return nil, err
+	}
+	if s.r.options.BackupFormat == BackupFormatJSON {
+		return append(data, '\n'), nil
+	}
+	data, err = yaml.JSONToYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("---\n"), data...), nil
+}
+
+// writeDocuments writes the documents of the archive one after the other.
+func writeDocuments(w io.Writer, documents [][]byte) error {
+	for _, document := range documents {
+		if _, err := w.Write(document); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *backupStep) writeFile(documents [][]byte) error {
+	var buf bytes.Buffer
+	if err := writeDocuments(&buf, documents); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(s.r.options.BackupPath, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("problem writing backup to %s :  %v", s.r.options.BackupPath, err)
+	}
+	log.Infof("Wrote backup of %d object(s) to %s", len(documents), s.r.options.BackupPath)
+	return nil
+}
+
+// chunkDocuments groups the documents into chunks of at most size bytes,
+// without splitting a document. A document larger than size gets a chunk of
+// its own.
+func chunkDocuments(documents [][]byte, size int) [][]byte {
+	var chunks [][]byte
+	var current []byte
+	for _, document := range documents {
+		if len(current) > 0 && len(current)+len(document) > size {
+			chunks = append(chunks, current)
+			current = nil
+		}
+		current = append(current, document...)
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// writeConfigMaps stores the archive in a set of ConfigMaps in the remover
+// namespace, labelled with the backup ID and annotated with their order.
+func (s *backupStep) writeConfigMaps(documents [][]byte) error {
+	s.id = strconv.FormatInt(s.r.start.Unix(), 10)
+	chunks := chunkDocuments(documents, backupChunkSize)
+	key := "backup." + s.r.options.BackupFormat
+	namespace := s.r.options.RemoverNamespace
+
+	if s.r.options.DryRun {
+		log.Infof("[dry-run] would write a backup of %d object(s) to %d ConfigMap(s) in %s", len(documents), len(chunks), namespace)
+		return nil
+	}
+
+	for i, chunk := range chunks {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("svcat-backup-%s-%d", s.id, i),
+				Namespace: namespace,
+				Labels:    map[string]string{backupLabel: s.id},
+				Annotations: map[string]string{
+					backupChunkAnnotation:  strconv.Itoa(i),
+					backupChunksAnnotation: strconv.Itoa(len(chunks)),
+				},
+			},
+			Data: map[string]string{key: string(chunk)},
+		}
+		err := s.r.retry(fmt.Sprintf("create ConfigMap %s", configMap.Name), func() error {
+			_, err := s.r.clients.Kube.CoreV1().ConfigMaps(namespace).Create(configMap)
+			if apierrors.IsAlreadyExists(err) {
+				_, err = s.r.clients.Kube.CoreV1().ConfigMaps(namespace).Update(configMap)
+			}
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("problem writing backup ConfigMap [%s/%s] :  %v", namespace, configMap.Name, err)
+		}
+		s.configMaps = append(s.configMaps, configMap.Name)
+	}
+	log.Infof("Wrote backup %s of %d object(s) to %d ConfigMap(s) in %s, labelled %s=%s at %s",
+		s.id, len(documents), len(chunks), namespace, backupLabel, s.id, s.r.start.Format(time.RFC3339))
+	return nil
+}
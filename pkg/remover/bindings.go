@@ -0,0 +1,182 @@
+package remover
+
+import (
+	"encoding/json"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+const serviceCatalogGroup = "servicecatalog.k8s.io"
+
+var (
+	serviceBindingsResource = schema.GroupVersionResource{Group: serviceCatalogGroup, Version: "v1beta1", Resource: "servicebindings"}
+	secretsResource         = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+)
+
+// isServiceCatalogOwner returns true if the owner reference points at a
+// servicecatalog.k8s.io object.
+func isServiceCatalogOwner(owner metav1.OwnerReference) bool {
+	gv, err := schema.ParseGroupVersion(owner.APIVersion)
+	if err != nil {
+		return false
+	}
+	return gv.Group == serviceCatalogGroup
+}
+
+// bindingSecretName returns the name of the secret a ServiceBinding writes its
+// credentials to. Service Catalog defaults it to the name of the binding.
+func bindingSecretName(binding *unstructured.Unstructured) string {
+	secretName, _, _ := unstructured.NestedString(binding.Object, "spec", "secretName")
+	if secretName == "" {
+		return binding.GetName()
+	}
+	return secretName
+}
+
+// preserveBindingSecretsStep removes the servicecatalog owner references from
+// the secrets of every ServiceBinding in the cluster, so the credentials are
+// not garbage collected along with the bindings when Service Catalog is torn
+// down.
+type preserveBindingSecretsStep struct {
+	r *Remover
+	// updated lists the secrets patched by Execute.
+	updated []types.NamespacedName
+}
+
+func (s *preserveBindingSecretsStep) Name() string {
+	return "preserve-binding-secrets"
+}
+
+// Required stops the pipeline if any binding secret could not be processed,
+// since the remaining steps would garbage collect it.
+func (s *preserveBindingSecretsStep) Required() bool {
+	return true
+}
+
+func (s *preserveBindingSecretsStep) Precondition() (bool, error) {
+	return true, nil
+}
+
+func (s *preserveBindingSecretsStep) Execute() error {
+	log.Info("Removing servicecatalog owner references from ServiceBinding secrets")
+	var bindings *unstructured.UnstructuredList
+	err := s.r.retry("list ServiceBindings", func() (err error) {
+		bindings, err = s.r.clients.Dynamic.Resource(serviceBindingsResource).Namespace(metav1.NamespaceAll).List(metav1.ListOptions{})
+		return err
+	})
+	if apierrors.IsNotFound(err) || s.r.serviceCatalogAPIGone(err) {
+		log.Info("ServiceBinding API is not available, no binding secrets to process.")
+		s.r.results.add("ServiceBinding", "", "", outcomeSkipped, "ServiceBinding API is not available")
+		return nil
+	} else if err != nil {
+		s.r.results.add("ServiceBinding", "", "", outcomeFailed, err.Error())
+		return fmt.Errorf("problem listing ServiceBindings :  %v", err)
+	}
+	log.Infof("Found %d service binding(s).", len(bindings.Items))
+
+	var updated, unchanged, missing int
+	var errs []error
+	for i := range bindings.Items {
+		binding := &bindings.Items[i]
+		namespace := binding.GetNamespace()
+		secretName := bindingSecretName(binding)
+		log.WithFields(objectFields("Secret", namespace, secretName)).WithField("binding", binding.GetName()).Info("Processing binding secret")
+
+		var o outcome
+		err := s.r.retry(fmt.Sprintf("update Secret %s/%s", namespace, secretName), func() (err error) {
+			o, err = s.stripOwnerReferences(namespace, secretName)
+			return err
+		})
+		switch {
+		case err != nil:
+			s.r.results.add("Secret", namespace, secretName, outcomeFailed, err.Error())
+			errs = append(errs, fmt.Errorf("problem removing servicecatalog owner references from secret [%s/%s] :  %v", namespace, secretName, err))
+		case o == outcomeAlreadyAbsent:
+			s.r.results.add("Secret", namespace, secretName, outcomeSkipped, "secret not found")
+			missing++
+		case o == outcomeSkipped:
+			s.r.results.add("Secret", namespace, secretName, outcomeSkipped, "no servicecatalog owner references")
+			unchanged++
+		default:
+			s.r.results.add("Secret", namespace, secretName, outcomeUpdated, "servicecatalog owner references removed")
+			s.updated = append(s.updated, types.NamespacedName{Namespace: namespace, Name: secretName})
+			updated++
+		}
+	}
+	log.Infof("Processed %d binding secret(s): %d updated, %d unchanged, %d not found, %d failed",
+		len(bindings.Items), updated, unchanged, missing, len(errs))
+	return utilerrors.NewAggregate(errs)
+}
+
+// stripOwnerReferences reads the secret and patches the servicecatalog owner
+// references off it. It reports outcomeAlreadyAbsent if the secret does not
+// exist and outcomeSkipped if it has no servicecatalog owner.
+func (s *preserveBindingSecretsStep) stripOwnerReferences(namespace, name string) (outcome, error) {
+	secrets := s.r.clients.Dynamic.Resource(secretsResource).Namespace(namespace)
+	secret, err := secrets.Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return outcomeAlreadyAbsent, nil
+	} else if err != nil {
+		return outcomeFailed, err
+	}
+
+	owners := []metav1.OwnerReference{}
+	for _, owner := range secret.GetOwnerReferences() {
+		if !isServiceCatalogOwner(owner) {
+			owners = append(owners, owner)
+		}
+	}
+	if len(owners) == len(secret.GetOwnerReferences()) {
+		return outcomeSkipped, nil
+	}
+
+	// The resourceVersion makes the patch fail on conflict rather than
+	// overwrite owner references changed since the Get.
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"ownerReferences": owners,
+			"resourceVersion": secret.GetResourceVersion(),
+		},
+	})
+	if err != nil {
+		return outcomeFailed, err
+	}
+	if _, err := secrets.Patch(name, types.MergePatchType, patch, s.r.patchOptions()); err != nil {
+		return outcomeFailed, err
+	}
+	return outcomeUpdated, nil
+}
+
+// Verify checks that no servicecatalog owner reference is left on the secrets
+// patched by Execute.
+func (s *preserveBindingSecretsStep) Verify() error {
+	var errs []error
+	for _, secret := range s.updated {
+		namespace, name := secret.Namespace, secret.Name
+		var obj *unstructured.Unstructured
+		err := s.r.retry(fmt.Sprintf("get Secret %s/%s", namespace, name), func() (err error) {
+			obj, err = s.r.clients.Dynamic.Resource(secretsResource).Namespace(namespace).Get(name, metav1.GetOptions{})
+			return err
+		})
+		if apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("secret [%s/%s] is gone", namespace, name))
+			continue
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("problem getting secret [%s/%s] :  %v", namespace, name, err))
+			continue
+		}
+		for _, owner := range obj.GetOwnerReferences() {
+			if isServiceCatalogOwner(owner) {
+				errs = append(errs, fmt.Errorf("secret [%s/%s] is still owned by %s %s", namespace, name, owner.Kind, owner.Name))
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,143 @@
+package remover
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	operatorapiv1 "github.com/openshift/api/operator/v1"
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// CheckpointName is the ConfigMap in the remover namespace recording the
+// outcome of every step, so a rerun resumes where an earlier run stopped.
+const CheckpointName = "svcat-remover-checkpoint"
+
+// checkpointUIDKey holds the UID of the ServiceCatalogAPIServer CR the
+// checkpoint was written for.
+const checkpointUIDKey = "custom-resource-uid"
+
+// checkpointEntry is the recorded outcome of a step, stored as JSON under the
+// step name.
+type checkpointEntry struct {
+	Outcome   outcome `json:"outcome"`
+	Timestamp string  `json:"timestamp"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// checkpoint records the outcome of the steps in the checkpoint ConfigMap as
+// they finish.
+type checkpoint struct {
+	r *Remover
+	// uid is the UID of the ServiceCatalogAPIServer CR, empty if the CR is
+	// already gone.
+	uid     types.UID
+	entries map[string]checkpointEntry
+}
+
+// loadCheckpoint reads the checkpoint left by earlier runs. A checkpoint
+// written for another ServiceCatalogAPIServer CR, or one that cannot be read,
+// is ignored, and so is every checkpoint in dry-run mode so the preview lists
+// every step.
+func (r *Remover) loadCheckpoint(operatorConfig *operatorapiv1.ServiceCatalogAPIServer) *checkpoint {
+	c := &checkpoint{r: r, entries: map[string]checkpointEntry{}}
+	if operatorConfig != nil {
+		c.uid = operatorConfig.UID
+	}
+	if r.options.ResetCheckpoint {
+		log.Info("Ignoring the checkpoint of earlier runs")
+		return c
+	}
+	if r.options.DryRun {
+		log.Info("Ignoring the checkpoint of earlier runs in dry-run mode")
+		return c
+	}
+
+	var configMap *corev1.ConfigMap
+	err := r.retry("get checkpoint ConfigMap", func() (err error) {
+		configMap, err = r.clients.Kube.CoreV1().ConfigMaps(r.options.RemoverNamespace).Get(CheckpointName, metav1.GetOptions{})
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		return c
+	} else if err != nil {
+		log.Warningf("problem reading the checkpoint [%s/%s], running every step :  %v", r.options.RemoverNamespace, CheckpointName, err)
+		return c
+	}
+
+	if uid := types.UID(configMap.Data[checkpointUIDKey]); uid != "" && c.uid != "" && uid != c.uid {
+		log.Infof("Checkpoint [%s/%s] was written for another ServiceCatalogAPIServer, ignoring it", r.options.RemoverNamespace, CheckpointName)
+		return c
+	} else if c.uid == "" {
+		c.uid = uid
+	}
+	for key, value := range configMap.Data {
+		if key == checkpointUIDKey {
+			continue
+		}
+		var entry checkpointEntry
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			log.Warningf("Ignoring invalid checkpoint entry %s :  %v", key, err)
+			continue
+		}
+		log.WithFields(log.Fields{"step": key, "outcome": entry.Outcome, "timestamp": entry.Timestamp}).Info("Checkpoint of an earlier run")
+		c.entries[key] = entry
+	}
+	return c
+}
+
+// completed returns the entry of a step an earlier run completed and verified.
+func (c *checkpoint) completed(name string) (checkpointEntry, bool) {
+	entry, ok := c.entries[name]
+	return entry, ok && entry.Outcome == outcomeCompleted
+}
+
+func (c *checkpoint) StepStarted(name string) {}
+
+// StepFinished records the outcome of the step. Nothing is written in dry-run
+// mode.
+func (c *checkpoint) StepFinished(name string, o outcome, message string) {
+	if c.r.options.DryRun {
+		return
+	}
+	entry := checkpointEntry{Outcome: o, Timestamp: time.Now().UTC().Format(time.RFC3339)}
+	if o == outcomeFailed {
+		entry.Error = message
+	}
+	c.entries[name] = entry
+	if err := c.save(); err != nil {
+		log.Warningf("problem writing the checkpoint [%s/%s] :  %v", c.r.options.RemoverNamespace, CheckpointName, err)
+	}
+}
+
+// save writes every entry to the checkpoint ConfigMap.
+func (c *checkpoint) save() error {
+	data := map[string]string{}
+	if c.uid != "" {
+		data[checkpointUIDKey] = string(c.uid)
+	}
+	for name, entry := range c.entries {
+		value, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		data[name] = string(value)
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: CheckpointName, Namespace: c.r.options.RemoverNamespace},
+		Data:       data,
+	}
+
+	configMaps := c.r.clients.Kube.CoreV1().ConfigMaps(c.r.options.RemoverNamespace)
+	return c.r.retry(fmt.Sprintf("write ConfigMap %s", CheckpointName), func() error {
+		_, err := configMaps.Update(configMap)
+		if apierrors.IsNotFound(err) {
+			_, err = configMaps.Create(configMap)
+		}
+		return err
+	})
+}
@@ -0,0 +1,102 @@
+package remover
+
+import (
+	"fmt"
+	"time"
+
+	operatorapiv1 "github.com/openshift/api/operator/v1"
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Defaults for the Service Catalog controller manager the remover waits for.
+const (
+	DefaultControllerManagerName      = "cluster"
+	DefaultControllerManagerNamespace = "openshift-service-catalog-controller-manager"
+	DefaultControllerManagerTimeout   = 10 * time.Minute
+)
+
+// controllerManagerPollInterval is how often the controller manager is checked
+// while waiting for its removal.
+var controllerManagerPollInterval = 5 * time.Second
+
+// controllerManagerStep makes sure the Service Catalog controller manager is
+// being removed and waits for it to be gone before the API server is torn
+// down, since the controller manager crash loops without its API server.
+type controllerManagerStep struct {
+	r *Remover
+	// removing is set by Execute when the controller manager is Unmanaged or
+	// Removed and Verify has to wait for it.
+	removing bool
+}
+
+func (s *controllerManagerStep) Name() string {
+	return "wait-for-controller-manager"
+}
+
+// Required stops the pipeline if the controller manager is not being removed.
+func (s *controllerManagerStep) Required() bool {
+	return true
+}
+
+func (s *controllerManagerStep) Precondition() (bool, error) {
+	return true, nil
+}
+
+func (s *controllerManagerStep) Execute() error {
+	name := s.r.options.ControllerManagerName
+	var operatorConfig *operatorapiv1.ServiceCatalogControllerManager
+	err := s.r.retry("get ServiceCatalogControllerManager", func() (err error) {
+		operatorConfig, err = s.r.clients.Operator.OperatorV1().ServiceCatalogControllerManagers().Get(name, metav1.GetOptions{})
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		log.Info("ServiceCatalogControllerManager cr has already been removed.")
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("problem getting ServiceCatalogControllerManager [%s] :  %v", name, err)
+	}
+
+	switch state := operatorConfig.Spec.ManagementState; state {
+	case operatorapiv1.Unmanaged, operatorapiv1.Removed:
+		log.Infof("ServiceCatalogControllerManager managementState is '%s', the controller manager is removed first", state)
+		s.removing = true
+		return nil
+	case operatorapiv1.Managed:
+		return fmt.Errorf("ServiceCatalogControllerManager [%s] is Managed, it must be Removed before the API server", name)
+	default:
+		return fmt.Errorf("unknown ServiceCatalogControllerManager managementState %q", state)
+	}
+}
+
+// Verify waits until the ServiceCatalogControllerManager CR or the controller
+// manager namespace is gone, unless waiting is disabled.
+func (s *controllerManagerStep) Verify() error {
+	if !s.removing || s.r.options.ControllerManagerTimeout <= 0 {
+		return nil
+	}
+	name, namespace := s.r.options.ControllerManagerName, s.r.options.ControllerManagerNamespace
+	log.Infof("Waiting up to %v for the Service Catalog controller manager to be removed", s.r.options.ControllerManagerTimeout)
+	err := s.r.poll(controllerManagerPollInterval, s.r.options.ControllerManagerTimeout, func() (bool, error) {
+		_, err := s.r.clients.Operator.OperatorV1().ServiceCatalogControllerManagers().Get(name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		} else if err != nil {
+			log.WithFields(objectFields("ServiceCatalogControllerManager", "", name)).WithError(err).Warning("problem getting the controller manager CR")
+			return false, nil
+		}
+		_, err = s.r.clients.Kube.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		} else if err != nil {
+			log.WithFields(objectFields("Namespace", "", namespace)).WithError(err).Warning("problem getting the controller manager namespace")
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("the Service Catalog controller manager was not removed within %v, namespace %s still exists", s.r.options.ControllerManagerTimeout, namespace)
+	}
+	return err
+}
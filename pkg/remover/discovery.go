@@ -0,0 +1,73 @@
+package remover
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// discoveredResource is a listable resource found through discovery.
+type discoveredResource struct {
+	gvr        schema.GroupVersionResource
+	kind       string
+	namespaced bool
+}
+
+// discoverGroupResources returns the listable resources served for the group
+// in its preferred version, sorted by resource name. It returns nothing if the
+// group is not registered with the API server.
+func (r *Remover) discoverGroupResources(group string) ([]discoveredResource, error) {
+	discovery := r.clients.Kube.Discovery()
+	var preferred string
+	err := r.retry("discover API groups", func() error {
+		groups, err := discovery.ServerGroups()
+		if err != nil {
+			return err
+		}
+		for _, g := range groups.Groups {
+			if g.Name == group {
+				preferred = g.PreferredVersion.GroupVersion
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if preferred == "" {
+		return nil, nil
+	}
+
+	var resources []discoveredResource
+	err = r.retry(fmt.Sprintf("discover %s resources", preferred), func() error {
+		resources = nil
+		list, err := discovery.ServerResourcesForGroupVersion(preferred)
+		if err != nil {
+			return err
+		}
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			return err
+		}
+		for _, resource := range list.APIResources {
+			// Skip subresources such as servicebindings/status.
+			if strings.Contains(resource.Name, "/") || !sets.NewString(resource.Verbs...).Has("list") {
+				continue
+			}
+			resources = append(resources, discoveredResource{
+				gvr:        gv.WithResource(resource.Name),
+				kind:       resource.Kind,
+				namespaced: resource.Namespaced,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].gvr.Resource < resources[j].gvr.Resource })
+	return resources, nil
+}
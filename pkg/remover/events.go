@@ -0,0 +1,87 @@
+package remover
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// DefaultJobName is the name of the Job running the remover, the involved
+// object of its events.
+const DefaultJobName = "openshift-service-catalog-apiserver-remover"
+
+// eventComponent is the source component of the events.
+const eventComponent = "openshift-service-catalog-apiserver-remover"
+
+// eventFlushDelay gives the broadcaster time to send the last events before
+// it is shut down.
+var eventFlushDelay = 2 * time.Second
+
+// Event reasons.
+const (
+	eventStepStarted   = "StepStarted"
+	eventStepCompleted = "StepCompleted"
+	eventStepSkipped   = "StepSkipped"
+	eventStepFailed    = "StepFailed"
+)
+
+// NewEventRecorder returns a recorder writing events to the namespace and a
+// function flushing the pending events and stopping the recorder.
+func NewEventRecorder(kubeClient kubernetes.Interface, namespace string) (record.EventRecorder, func()) {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events(namespace)})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: eventComponent})
+	return recorder, func() {
+		time.Sleep(eventFlushDelay)
+		broadcaster.Shutdown()
+	}
+}
+
+// stepEvents records an event on the remover Job for every step, so the
+// actions of the remover outlive the logs of its pod.
+type stepEvents struct {
+	r   *Remover
+	job *corev1.ObjectReference
+}
+
+// newStepEvents looks up the Job running the remover. Events only show up
+// on the Job when they carry its UID, so a Job that cannot be found is
+// logged and its events are recorded by name alone.
+func newStepEvents(r *Remover) *stepEvents {
+	reference := &corev1.ObjectReference{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Namespace:  r.options.RemoverNamespace,
+		Name:       r.options.JobName,
+	}
+	job, err := r.clients.Kube.BatchV1().Jobs(r.options.RemoverNamespace).Get(r.options.JobName, metav1.GetOptions{})
+	if err != nil {
+		log.Warningf("problem looking up the remover job [%s/%s], recording its events without its uid :  %v", r.options.RemoverNamespace, r.options.JobName, err)
+	} else {
+		reference.UID = job.UID
+		reference.ResourceVersion = job.ResourceVersion
+	}
+	return &stepEvents{r: r, job: reference}
+}
+
+func (e *stepEvents) StepStarted(name string) {
+	e.r.clients.Recorder.Eventf(e.job, corev1.EventTypeNormal, eventStepStarted, "Running step %s", name)
+}
+
+func (e *stepEvents) StepFinished(name string, o outcome, message string) {
+	switch o {
+	case outcomeFailed:
+		e.r.clients.Recorder.Eventf(e.job, corev1.EventTypeWarning, eventStepFailed, "Step %s failed: %s", name, message)
+	case outcomeSkipped:
+		e.r.clients.Recorder.Eventf(e.job, corev1.EventTypeNormal, eventStepSkipped, "Step %s skipped: %s", name, message)
+	default:
+		e.r.clients.Recorder.Event(e.job, corev1.EventTypeNormal, eventStepCompleted, fmt.Sprintf("Step %s completed", name))
+	}
+}
@@ -0,0 +1,146 @@
+package remover
+
+import (
+	"encoding/json"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// serviceCatalogFinalizer is set by the Service Catalog controller manager
+// and only cleared by it.
+const serviceCatalogFinalizer = "kubernetes-incubator/service-catalog"
+
+// finalizerResources are the resources whose finalizers are stripped.
+var finalizerResources = []struct {
+	kind string
+	gvr  schema.GroupVersionResource
+}{
+	{"ServiceInstance", serviceInstancesResource},
+	{"ServiceBinding", serviceBindingsResource},
+}
+
+// hasServiceCatalogFinalizer returns true if the object carries the Service
+// Catalog finalizer.
+func hasServiceCatalogFinalizer(obj *unstructured.Unstructured) bool {
+	for _, finalizer := range obj.GetFinalizers() {
+		if finalizer == serviceCatalogFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// stripFinalizersStep removes the Service Catalog finalizer from every
+// ServiceInstance and ServiceBinding while the aggregated API is reachable.
+// Once the controller manager is gone nothing clears it, and the objects would
+// keep their namespaces from being deleted.
+type stripFinalizersStep struct {
+	r *Remover
+}
+
+func (s *stripFinalizersStep) Name() string {
+	return "strip-finalizers"
+}
+
+// Precondition skips the step unless it was opted into.
+func (s *stripFinalizersStep) Precondition() (bool, error) {
+	if !s.r.options.StripFinalizers {
+		log.Info("Stripping Service Catalog finalizers is not enabled")
+		return false, nil
+	}
+	return true, nil
+}
+
+func (s *stripFinalizersStep) Execute() error {
+	log.Infof("Removing the %s finalizer from ServiceInstances and ServiceBindings", serviceCatalogFinalizer)
+	var errs []error
+	for _, resource := range finalizerResources {
+		objects, err := s.r.listAll(resource.gvr)
+		if err != nil {
+			s.r.results.add(resource.kind, "", "", outcomeFailed, err.Error())
+			errs = append(errs, err)
+			continue
+		}
+		for i := range objects {
+			if !hasServiceCatalogFinalizer(&objects[i]) {
+				continue
+			}
+			namespace, name := objects[i].GetNamespace(), objects[i].GetName()
+			var o outcome
+			err := s.r.retry(fmt.Sprintf("update %s %s/%s", resource.kind, namespace, name), func() (err error) {
+				o, err = s.stripFinalizer(resource.gvr, namespace, name)
+				return err
+			})
+			switch {
+			case err != nil:
+				s.r.results.add(resource.kind, namespace, name, outcomeFailed, err.Error())
+				errs = append(errs, fmt.Errorf("problem removing the finalizer from %s [%s/%s] :  %v", resource.kind, namespace, name, err))
+			case o == outcomeUpdated:
+				s.r.results.add(resource.kind, namespace, name, outcomeUpdated, fmt.Sprintf("finalizer %s removed", serviceCatalogFinalizer))
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// stripFinalizer reads the object and patches the Service Catalog finalizer
+// off it. It reports outcomeSkipped if the object is gone or no longer has
+// the finalizer.
+func (s *stripFinalizersStep) stripFinalizer(gvr schema.GroupVersionResource, namespace, name string) (outcome, error) {
+	client := s.r.clients.Dynamic.Resource(gvr).Namespace(namespace)
+	obj, err := client.Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return outcomeSkipped, nil
+	} else if err != nil {
+		return outcomeFailed, err
+	}
+	if !hasServiceCatalogFinalizer(obj) {
+		return outcomeSkipped, nil
+	}
+
+	finalizers := []string{}
+	for _, finalizer := range obj.GetFinalizers() {
+		if finalizer != serviceCatalogFinalizer {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+	// The resourceVersion makes the patch fail on conflict rather than
+	// overwrite finalizers changed since the Get.
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers":      finalizers,
+			"resourceVersion": obj.GetResourceVersion(),
+		},
+	})
+	if err != nil {
+		return outcomeFailed, err
+	}
+	if _, err := client.Patch(name, types.MergePatchType, patch, s.r.patchOptions()); err != nil {
+		return outcomeFailed, err
+	}
+	return outcomeUpdated, nil
+}
+
+// Verify checks that no ServiceInstance or ServiceBinding still carries the
+// Service Catalog finalizer.
+func (s *stripFinalizersStep) Verify() error {
+	for _, resource := range finalizerResources {
+		objects, err := s.r.listAll(resource.gvr)
+		if err != nil {
+			return err
+		}
+		for i := range objects {
+			if hasServiceCatalogFinalizer(&objects[i]) {
+				return fmt.Errorf("%s [%s/%s] still has the %s finalizer", resource.kind, objects[i].GetNamespace(), objects[i].GetName(), serviceCatalogFinalizer)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package remover
+
+import (
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// errInterrupted is returned by polls cut short by Stop.
+var errInterrupted = errors.New("interrupted by a termination signal")
+
+// Stop asks the run to finish the current step and not start any other. It is
+// safe to call from another goroutine, typically a signal handler, and more
+// than once.
+func (r *Remover) Stop() {
+	r.stopOnce.Do(func() {
+		log.Warning("Stopping: the current step finishes, no further step starts")
+		close(r.stopCh)
+	})
+}
+
+// stopped returns true once Stop has been called.
+func (r *Remover) stopped() bool {
+	select {
+	case <-r.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// stepTimeouts starts the per-step deadline of every step.
+type stepTimeouts struct {
+	r *Remover
+}
+
+func (s *stepTimeouts) StepStarted(name string) {
+	if s.r.options.StepTimeout > 0 {
+		s.r.stepDeadline = time.Now().Add(s.r.options.StepTimeout)
+	}
+}
+
+func (s *stepTimeouts) StepFinished(name string, o outcome, message string) {
+	s.r.stepDeadline = time.Time{}
+}
+
+// pollTimeout bounds timeout by the deadline of the current step and of the
+// run.
+func (r *Remover) pollTimeout(timeout time.Duration) time.Duration {
+	deadlines := []time.Time{r.stepDeadline}
+	if r.options.Timeout > 0 {
+		deadlines = append(deadlines, r.start.Add(r.options.Timeout))
+	}
+	for _, deadline := range deadlines {
+		if remaining := time.Until(deadline); !deadline.IsZero() && remaining < timeout {
+			timeout = remaining
+		}
+	}
+	if timeout < 0 {
+		return 0
+	}
+	return timeout
+}
+
+// poll calls condition every interval until it returns true or an error, the
+// timeout bounded by the step and run deadlines passes, or the run is
+// stopped. It returns wait.ErrWaitTimeout on timeout and errInterrupted when
+// stopped.
+func (r *Remover) poll(interval, timeout time.Duration, condition wait.ConditionFunc) error {
+	timer := time.NewTimer(r.pollTimeout(timeout))
+	defer timer.Stop()
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		defer close(done)
+		select {
+		case <-timer.C:
+		case <-r.stopCh:
+		case <-finished:
+		}
+	}()
+
+	err := wait.PollImmediateUntil(interval, condition, done)
+	if err == wait.ErrWaitTimeout && r.stopped() {
+		return errInterrupted
+	}
+	return err
+}
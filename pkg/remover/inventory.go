@@ -0,0 +1,253 @@
+package remover
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var (
+	serviceInstancesResource      = schema.GroupVersionResource{Group: serviceCatalogGroup, Version: "v1beta1", Resource: "serviceinstances"}
+	clusterServiceBrokersResource = schema.GroupVersionResource{Group: serviceCatalogGroup, Version: "v1beta1", Resource: "clusterservicebrokers"}
+	serviceBrokersResource        = schema.GroupVersionResource{Group: serviceCatalogGroup, Version: "v1beta1", Resource: "servicebrokers"}
+)
+
+// NamespaceUsage counts the Service Catalog objects of a namespace.
+type NamespaceUsage struct {
+	Namespace        string `json:"namespace"`
+	ServiceInstances int    `json:"serviceInstances"`
+	ServiceBindings  int    `json:"serviceBindings"`
+}
+
+// OwnedSecret is a secret still owned by a servicecatalog object.
+type OwnedSecret struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	OwnerKind string `json:"ownerKind"`
+	OwnerName string `json:"ownerName"`
+}
+
+// Broker is a ClusterServiceBroker or a namespaced ServiceBroker.
+type Broker struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	URL       string `json:"url"`
+}
+
+// SecretConsumer is a pod using owned secrets through volumes or its
+// environment.
+type SecretConsumer struct {
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Secrets   []string `json:"secrets"`
+}
+
+// Inventory describes what still depends on Service Catalog in the cluster.
+type Inventory struct {
+	Namespaces      []NamespaceUsage `json:"namespaces"`
+	OwnedSecrets    []OwnedSecret    `json:"ownedSecrets"`
+	Brokers         []Broker         `json:"brokers"`
+	SecretConsumers []SecretConsumer `json:"secretConsumers"`
+}
+
+// listAll lists the objects of a resource in every namespace. A resource the
+// API server does not serve has no objects. A ServiceUnavailable error is
+// returned unwrapped so callers can tell that the API is not available.
+func (r *Remover) listAll(gvr schema.GroupVersionResource) ([]unstructured.Unstructured, error) {
+	var list *unstructured.UnstructuredList
+	err := r.retry(fmt.Sprintf("list %s", gvr.Resource), func() (err error) {
+		list, err = r.clients.Dynamic.Resource(gvr).Namespace(metav1.NamespaceAll).List(metav1.ListOptions{})
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if apierrors.IsServiceUnavailable(err) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("problem listing %s :  %v", gvr.Resource, err)
+	}
+	return list.Items, nil
+}
+
+// Inventory collects the Service Catalog usage of the cluster without
+// changing anything.
+func (r *Remover) Inventory() (*Inventory, error) {
+	inventory := &Inventory{}
+
+	instances, err := r.listAll(serviceInstancesResource)
+	if err != nil {
+		return nil, err
+	}
+	bindings, err := r.listAll(serviceBindingsResource)
+	if err != nil {
+		return nil, err
+	}
+	inventory.Namespaces = countByNamespace(instances, bindings)
+
+	for _, gvr := range []schema.GroupVersionResource{clusterServiceBrokersResource, serviceBrokersResource} {
+		brokers, err := r.listAll(gvr)
+		if err != nil {
+			return nil, err
+		}
+		for _, broker := range brokers {
+			url, _, _ := unstructured.NestedString(broker.Object, "spec", "url")
+			inventory.Brokers = append(inventory.Brokers, Broker{
+				Kind:      broker.GetKind(),
+				Namespace: broker.GetNamespace(),
+				Name:      broker.GetName(),
+				URL:       url,
+			})
+		}
+	}
+
+	secrets, err := r.listAll(secretsResource)
+	if err != nil {
+		return nil, err
+	}
+	owned := map[string]sets.String{}
+	for _, secret := range secrets {
+		for _, owner := range secret.GetOwnerReferences() {
+			if !isServiceCatalogOwner(owner) {
+				continue
+			}
+			inventory.OwnedSecrets = append(inventory.OwnedSecrets, OwnedSecret{
+				Namespace: secret.GetNamespace(),
+				Name:      secret.GetName(),
+				OwnerKind: owner.Kind,
+				OwnerName: owner.Name,
+			})
+			if owned[secret.GetNamespace()] == nil {
+				owned[secret.GetNamespace()] = sets.NewString()
+			}
+			owned[secret.GetNamespace()].Insert(secret.GetName())
+		}
+	}
+
+	namespaces := make([]string, 0, len(owned))
+	for namespace := range owned {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		var pods *corev1.PodList
+		err := r.retry(fmt.Sprintf("list Pods in %s", namespace), func() (err error) {
+			pods, err = r.clients.Kube.CoreV1().Pods(namespace).List(metav1.ListOptions{})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("problem listing pods in %s :  %v", namespace, err)
+		}
+		for i := range pods.Items {
+			used := podSecrets(&pods.Items[i]).Intersection(owned[namespace])
+			if used.Len() == 0 {
+				continue
+			}
+			inventory.SecretConsumers = append(inventory.SecretConsumers, SecretConsumer{
+				Namespace: namespace,
+				Name:      pods.Items[i].Name,
+				Secrets:   used.List(),
+			})
+		}
+	}
+	return inventory, nil
+}
+
+// countByNamespace returns the number of ServiceInstances and ServiceBindings
+// of every namespace having any, sorted by namespace.
+func countByNamespace(instances, bindings []unstructured.Unstructured) []NamespaceUsage {
+	usage := map[string]*NamespaceUsage{}
+	get := func(namespace string) *NamespaceUsage {
+		if usage[namespace] == nil {
+			usage[namespace] = &NamespaceUsage{Namespace: namespace}
+		}
+		return usage[namespace]
+	}
+	for _, instance := range instances {
+		get(instance.GetNamespace()).ServiceInstances++
+	}
+	for _, binding := range bindings {
+		get(binding.GetNamespace()).ServiceBindings++
+	}
+
+	counts := make([]NamespaceUsage, 0, len(usage))
+	for _, u := range usage {
+		counts = append(counts, *u)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Namespace < counts[j].Namespace })
+	return counts
+}
+
+// podSecrets returns the names of the secrets a pod mounts or reads into its
+// environment.
+func podSecrets(pod *corev1.Pod) sets.String {
+	names := sets.NewString()
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Secret != nil {
+			names.Insert(volume.Secret.SecretName)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil {
+					names.Insert(source.Secret.Name)
+				}
+			}
+		}
+	}
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, container := range containers {
+		for _, from := range container.EnvFrom {
+			if from.SecretRef != nil {
+				names.Insert(from.SecretRef.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				names.Insert(env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+	return names
+}
+
+// WriteJSON writes the inventory as an indented JSON document.
+func (i *Inventory) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(i)
+}
+
+// WriteTable writes the inventory as one table per section.
+func (i *Inventory) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tSERVICEINSTANCES\tSERVICEBINDINGS")
+	for _, u := range i.Namespaces {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", u.Namespace, u.ServiceInstances, u.ServiceBindings)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "BROKER KIND\tNAMESPACE\tNAME\tURL")
+	for _, b := range i.Brokers {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", b.Kind, b.Namespace, b.Name, b.URL)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "SECRET NAMESPACE\tSECRET\tOWNER KIND\tOWNER")
+	for _, s := range i.OwnedSecrets {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Namespace, s.Name, s.OwnerKind, s.OwnerName)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "POD NAMESPACE\tPOD\tSECRETS")
+	for _, c := range i.SecretConsumers {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", c.Namespace, c.Name, strings.Join(c.Secrets, ","))
+	}
+	return tw.Flush()
+}
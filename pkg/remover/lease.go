@@ -0,0 +1,215 @@
+package remover
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// LeaseName is the Lease in the remover namespace held by the running remover,
+// so two runs never act on the cluster at the same time.
+const LeaseName = "svcat-remover"
+
+// DefaultLeaseDuration is how long a Lease that is not renewed keeps other
+// runs out. It is renewed every third of the duration.
+const DefaultLeaseDuration = 60 * time.Second
+
+// leasePollInterval is how often a Lease held by another run is checked while
+// waiting for it.
+var leasePollInterval = 5 * time.Second
+
+// errLeaseHeld is returned when another run holds the Lease.
+type errLeaseHeld struct {
+	holder string
+}
+
+func (e *errLeaseHeld) Error() string {
+	return fmt.Sprintf("lease %s is held by %s", LeaseName, e.holder)
+}
+
+// defaultIdentity returns the identity the remover holds the Lease under, the
+// name of its pod when running in-cluster.
+func defaultIdentity() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "svcat-remover"
+	}
+	return fmt.Sprintf("%s_%d", hostname, os.Getpid())
+}
+
+// leaseLock is a Lease held by this run, renewed in the background until it
+// is released.
+type leaseLock struct {
+	r    *Remover
+	stop chan struct{}
+	done chan struct{}
+}
+
+// acquireLease takes the Lease, waiting up to Options.LeaseWait for another
+// live holder to release it or let it expire.
+func (r *Remover) acquireLease() (*leaseLock, error) {
+	var err error
+	if r.options.LeaseWait <= 0 {
+		err = r.retry("acquire Lease", r.tryAcquireLease)
+	} else {
+		var held error
+		err = r.poll(leasePollInterval, r.options.LeaseWait, func() (bool, error) {
+			err := r.retry("acquire Lease", r.tryAcquireLease)
+			if holder, ok := err.(*errLeaseHeld); ok {
+				log.Infof("Lease %s is held by %s, waiting", LeaseName, holder.holder)
+				held = err
+				return false, nil
+			}
+			return err == nil, err
+		})
+		if err == wait.ErrWaitTimeout {
+			err = held
+		}
+	}
+	if _, ok := err.(*errLeaseHeld); ok {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("problem acquiring lease [%s/%s] :  %v", r.options.RemoverNamespace, LeaseName, err)
+	}
+	log.Infof("Acquired lease %s as %s", LeaseName, r.options.Identity)
+
+	lock := &leaseLock{r: r, stop: make(chan struct{}), done: make(chan struct{})}
+	go lock.renew()
+	return lock, nil
+}
+
+// tryAcquireLease creates the Lease or takes it over if it is free, expired
+// or already ours. It returns errLeaseHeld if another live run holds it.
+func (r *Remover) tryAcquireLease() error {
+	leases := r.clients.Kube.CoordinationV1().Leases(r.options.RemoverNamespace)
+	now := metav1.NewMicroTime(time.Now())
+	identity := r.options.Identity
+	seconds := int32(r.options.LeaseDuration / time.Second)
+
+	lease, err := leases.Get(LeaseName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = leases.Create(&coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: LeaseName, Namespace: r.options.RemoverNamespace},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &identity,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		})
+		if apierrors.IsAlreadyExists(err) {
+			// Another run created it first, report a conflict to read it.
+			return apierrors.NewConflict(coordinationv1.Resource("leases"), LeaseName, err)
+		}
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if holder := leaseHolder(lease); holder != "" && holder != identity && !leaseExpired(lease, now.Time) {
+		return &errLeaseHeld{holder: holder}
+	}
+	if leaseHolder(lease) != identity {
+		transitions := int32(1)
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions + 1
+		}
+		lease.Spec.LeaseTransitions = &transitions
+		lease.Spec.AcquireTime = &now
+	}
+	lease.Spec.HolderIdentity = &identity
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(lease)
+	return err
+}
+
+// leaseHolder returns the holder of the Lease, empty if it is free.
+func leaseHolder(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// leaseExpired returns true if the holder of the Lease stopped renewing it.
+func leaseExpired(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second).Before(now)
+}
+
+// renew renews the Lease every third of its duration until released. The run
+// is stopped once another run takes the Lease over, or renewals keep failing
+// for longer than the Lease duration so another run may have taken it.
+func (l *leaseLock) renew() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.r.options.LeaseDuration / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			err := l.r.retry("renew Lease", l.r.tryAcquireLease)
+			if _, ok := err.(*errLeaseHeld); ok {
+				l.lost(err)
+				return
+			} else if err != nil {
+				log.Errorf("problem renewing lease [%s/%s] :  %v", l.r.options.RemoverNamespace, LeaseName, err)
+				if time.Since(renewed) >= l.r.options.LeaseDuration {
+					l.lost(fmt.Errorf("lease %s not renewed for %v", LeaseName, time.Since(renewed).Round(time.Second)))
+					return
+				}
+			} else {
+				renewed = time.Now()
+			}
+		}
+	}
+}
+
+// lost stops the run, which no longer holds the Lease.
+func (l *leaseLock) lost(err error) {
+	log.Errorf("Lost lease %s, stopping: %v", LeaseName, err)
+	atomic.StoreInt32(&l.r.leaseLost, 1)
+	l.r.Stop()
+}
+
+// lostLease returns true once the run lost the Lease it held.
+func (r *Remover) lostLease() bool {
+	return atomic.LoadInt32(&r.leaseLost) == 1
+}
+
+// release stops renewing the Lease and frees it for the next run.
+func (l *leaseLock) release() {
+	close(l.stop)
+	<-l.done
+
+	leases := l.r.clients.Kube.CoordinationV1().Leases(l.r.options.RemoverNamespace)
+	err := l.r.retry("release Lease", func() error {
+		lease, err := leases.Get(LeaseName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if leaseHolder(lease) != l.r.options.Identity {
+			return nil
+		}
+		lease.Spec.HolderIdentity = nil
+		_, err = leases.Update(lease)
+		return err
+	})
+	if err != nil {
+		log.Warningf("problem releasing lease [%s/%s] :  %v", l.r.options.RemoverNamespace, LeaseName, err)
+		return
+	}
+	log.Infof("Released lease %s", LeaseName)
+}
@@ -0,0 +1,165 @@
+package remover
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// DefaultLegacyNamespace is where releases before 4.1 installed both the
+// Service Catalog API server and controller manager.
+const DefaultLegacyNamespace = "kube-service-catalog"
+
+// legacyResources are the cluster scoped resources searched for objects left
+// by older Service Catalog installs, by API group.
+var legacyResources = map[string]sets.String{
+	"rbac.authorization.k8s.io":    sets.NewString("clusterroles", "clusterrolebindings"),
+	"admissionregistration.k8s.io": sets.NewString("validatingwebhookconfigurations", "mutatingwebhookconfigurations"),
+	"apiextensions.k8s.io":         sets.NewString("customresourcedefinitions"),
+}
+
+// legacyNamePrefixes are the name prefixes of the objects older Service
+// Catalog installs created.
+var legacyNamePrefixes = []string{"servicecatalog-", "servicecatalog.k8s.io:", "service-catalog-", "svcat-", "system:openshift:service-catalog:"}
+
+// legacyLabels are the label values older Service Catalog installs set, by
+// label key.
+var legacyLabels = map[string]sets.String{
+	"app":                    sets.NewString("service-catalog", "servicecatalog", "svcat"),
+	"app.kubernetes.io/name": sets.NewString("service-catalog"),
+}
+
+// legacyExcludedPrefixes are name prefixes of objects that look like legacy
+// objects but belong to the current operators and removers, including this
+// one.
+var legacyExcludedPrefixes = []string{"openshift-service-catalog-", "system:openshift:operator:"}
+
+// hasPrefix returns true if s starts with one of the prefixes.
+func hasPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLegacyObject returns true if the object was left by an older Service
+// Catalog install. CustomResourceDefinitions match on their group, other
+// objects on a known name prefix or label.
+func isLegacyObject(resource string, obj *unstructured.Unstructured) bool {
+	if resource == "customresourcedefinitions" {
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		return group == serviceCatalogGroup
+	}
+	if hasPrefix(obj.GetName(), legacyExcludedPrefixes) {
+		return false
+	}
+	if hasPrefix(obj.GetName(), legacyNamePrefixes) {
+		return true
+	}
+	for key, value := range obj.GetLabels() {
+		if legacyLabels[key].Has(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// legacyClusterResourcesStep removes the aggregated ClusterRoles, bindings,
+// webhook configurations and CustomResourceDefinitions left by older Service
+// Catalog installs, found through discovery.
+type legacyClusterResourcesStep struct {
+	r *Remover
+}
+
+func (s *legacyClusterResourcesStep) Name() string {
+	return "delete-legacy-cluster-resources"
+}
+
+func (s *legacyClusterResourcesStep) Precondition() (bool, error) {
+	return true, nil
+}
+
+// legacyObject is an object matched by isLegacyObject.
+type legacyObject struct {
+	resource discoveredResource
+	name     string
+}
+
+// find returns the legacy objects of every served legacy resource.
+func (s *legacyClusterResourcesStep) find() ([]legacyObject, error) {
+	groups := make([]string, 0, len(legacyResources))
+	for group := range legacyResources {
+		groups = append(groups, group)
+	}
+	var found []legacyObject
+	for _, group := range sets.NewString(groups...).List() {
+		resources, err := s.r.discoverGroupResources(group)
+		if err != nil {
+			return nil, fmt.Errorf("problem discovering %s resources :  %v", group, err)
+		}
+		for _, resource := range resources {
+			if resource.namespaced || !legacyResources[group].Has(resource.gvr.Resource) {
+				continue
+			}
+			objects, err := s.r.listAll(resource.gvr)
+			if err != nil {
+				return nil, err
+			}
+			for i := range objects {
+				if isLegacyObject(resource.gvr.Resource, &objects[i]) {
+					found = append(found, legacyObject{resource: resource, name: objects[i].GetName()})
+				}
+			}
+		}
+	}
+	return found, nil
+}
+
+func (s *legacyClusterResourcesStep) Execute() error {
+	log.Info("Removing cluster resources left by older Service Catalog installs")
+	objects, err := s.find()
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		log.Info("No cluster resources left by older Service Catalog installs")
+		return nil
+	}
+
+	var errs []error
+	for _, obj := range objects {
+		kind, name := obj.resource.kind, obj.name
+		if s.r.options.DryRun {
+			log.WithFields(objectFields(kind, "", name)).Info("[dry-run] exists and would be deleted")
+		}
+		err := s.r.deleteObject(kind, "", name, func(options *metav1.DeleteOptions) error {
+			return s.r.clients.Dynamic.Resource(obj.resource.gvr).Delete(name, options)
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("problem removing %s [%s] :  %v", kind, name, err))
+			continue
+		}
+		s.r.reportDryRunResult(kind, name, err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// Verify checks that no legacy cluster resource is left.
+func (s *legacyClusterResourcesStep) Verify() error {
+	objects, err := s.find()
+	if err != nil {
+		return err
+	}
+	if len(objects) > 0 {
+		return fmt.Errorf("%s [%s] is still present", objects[0].resource.kind, objects[0].name)
+	}
+	return nil
+}
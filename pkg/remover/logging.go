@@ -0,0 +1,100 @@
+package remover
+
+import (
+	"fmt"
+	"sync"
+
+	operatorapiv1 "github.com/openshift/api/operator/v1"
+	log "github.com/sirupsen/logrus"
+)
+
+// logLevels maps the intent based log levels of the operator API to logrus
+// levels.
+var logLevels = map[operatorapiv1.LogLevel]log.Level{
+	"":                     log.InfoLevel,
+	operatorapiv1.Normal:   log.InfoLevel,
+	operatorapiv1.Debug:    log.DebugLevel,
+	operatorapiv1.Trace:    log.TraceLevel,
+	operatorapiv1.TraceAll: log.TraceLevel,
+}
+
+// ValidateLogLevel returns an error if level is not a log level of the
+// operator API. Empty is valid and selects the level of the CR.
+func ValidateLogLevel(level string) error {
+	if _, ok := logLevels[operatorapiv1.LogLevel(level)]; !ok {
+		return fmt.Errorf("unsupported log level %q, valid levels are %s, %s, %s and %s",
+			level, operatorapiv1.Normal, operatorapiv1.Debug, operatorapiv1.Trace, operatorapiv1.TraceAll)
+	}
+	return nil
+}
+
+// setLogLevel applies the log level of the operator API to the logger.
+func setLogLevel(level operatorapiv1.LogLevel, source string) {
+	logLevel, ok := logLevels[level]
+	if !ok {
+		log.Warningf("Ignoring unsupported log level %q from %s", level, source)
+		return
+	}
+	log.SetLevel(logLevel)
+	log.Debugf("Log level set to %s from %s", logLevel, source)
+}
+
+// applyLogLevel sets the log level from Options.LogLevel, or else from the
+// operatorLogLevel of the CR, falling back to its logLevel. The CR is nil if
+// it does not exist.
+func (r *Remover) applyLogLevel(operatorConfig *operatorapiv1.ServiceCatalogAPIServer) {
+	switch {
+	case r.options.LogLevel != "":
+		setLogLevel(operatorapiv1.LogLevel(r.options.LogLevel), "--log-level")
+	case operatorConfig == nil:
+	case operatorConfig.Spec.OperatorLogLevel != "":
+		setLogLevel(operatorConfig.Spec.OperatorLogLevel, "spec.operatorLogLevel")
+	default:
+		setLogLevel(operatorConfig.Spec.LogLevel, "spec.logLevel")
+	}
+}
+
+// stepField adds the running step to every log entry, so the entries of a
+// step can be found by its name.
+type stepField struct {
+	lock sync.Mutex
+	step string
+}
+
+// currentStep is registered with the standard logger and set by the pipeline
+// observer below.
+var currentStep = &stepField{}
+
+func init() {
+	log.AddHook(currentStep)
+}
+
+func (s *stepField) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (s *stepField) Fire(entry *log.Entry) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := entry.Data["step"]; !ok && s.step != "" {
+		entry.Data["step"] = s.step
+	}
+	return nil
+}
+
+func (s *stepField) set(step string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.step = step
+}
+
+// stepLogging tracks the running step for the step log field.
+type stepLogging struct{}
+
+func (stepLogging) StepStarted(name string) {
+	currentStep.set(name)
+}
+
+func (stepLogging) StepFinished(name string, o outcome, message string) {
+	currentStep.set("")
+}
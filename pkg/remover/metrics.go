@@ -0,0 +1,215 @@
+package remover
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric names.
+const (
+	metricObjectsDeleted = "svcat_remover_objects_deleted_total"
+	metricStepDuration   = "svcat_remover_step_duration_seconds"
+	metricStepFailures   = "svcat_remover_step_failures_total"
+	metricRetries        = "svcat_remover_retries_total"
+	metricSuccess        = "svcat_remover_success"
+)
+
+// managementStateLabel labels every series with the ManagementState the run
+// acted on.
+const managementStateLabel = "management_state"
+
+// Values of the management_state label besides the ManagementState of the
+// ServiceCatalogAPIServer CR.
+const (
+	branchNotFound = "NotFound"
+	branchError    = "Error"
+	branchUnknown  = "Unknown"
+)
+
+// sample is the value of a metric for a label set.
+type sample struct {
+	labels map[string]string
+	value  float64
+}
+
+// metricFamily is a metric and its samples by rendered label set.
+type metricFamily struct {
+	help       string
+	metricType string
+	samples    map[string]*sample
+}
+
+// Metrics holds the metrics of a run and renders them in the Prometheus text
+// exposition format, both for scraping and for the node exporter textfile
+// collector or a Pushgateway.
+type Metrics struct {
+	lock     sync.Mutex
+	families map[string]*metricFamily
+	// managementState labels every series once the run knows it.
+	managementState string
+}
+
+// NewMetrics returns the metrics of a run with nothing recorded.
+func NewMetrics() *Metrics {
+	m := &Metrics{families: map[string]*metricFamily{}}
+	m.register(metricObjectsDeleted, "counter", "Objects deleted by the remover, by kind.")
+	m.register(metricStepDuration, "gauge", "Duration of the last run of each remover step.")
+	m.register(metricStepFailures, "counter", "Failed remover steps.")
+	m.register(metricRetries, "counter", "API calls retried after a transient error.")
+	m.register(metricSuccess, "gauge", "1 if the remover run succeeded, 0 if it failed.")
+	return m
+}
+
+func (m *Metrics) register(name, metricType, help string) {
+	m.families[name] = &metricFamily{help: help, metricType: metricType, samples: map[string]*sample{}}
+}
+
+// setManagementState sets the management_state label of every series.
+func (m *Metrics) setManagementState(state string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.managementState = state
+}
+
+// renderLabels returns the label set in exposition format, sorted by name.
+func renderLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escaper.Replace(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// sample returns the sample of the metric for the label set, creating it.
+func (m *Metrics) sample(name string, labels map[string]string) *sample {
+	samples := m.families[name].samples
+	key := renderLabels(labels)
+	if _, ok := samples[key]; !ok {
+		samples[key] = &sample{labels: labels}
+	}
+	return samples[key]
+}
+
+func (m *Metrics) add(name string, labels map[string]string, value float64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.sample(name, labels).value += value
+}
+
+func (m *Metrics) set(name string, labels map[string]string, value float64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.sample(name, labels).value = value
+}
+
+// renderSample returns the label set of the sample with the management_state
+// label, in exposition format.
+func (m *Metrics) renderSample(s *sample) string {
+	if m.managementState == "" {
+		return renderLabels(s.labels)
+	}
+	labels := map[string]string{managementStateLabel: m.managementState}
+	for name, value := range s.labels {
+		labels[name] = value
+	}
+	return renderLabels(labels)
+}
+
+// Write writes every metric with at least one sample, sorted by name.
+func (m *Metrics) Write(w io.Writer) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		family := m.families[name]
+		if len(family.samples) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, family.help, name, family.metricType); err != nil {
+			return err
+		}
+		values := make(map[string]float64, len(family.samples))
+		labelSets := make([]string, 0, len(family.samples))
+		for _, s := range family.samples {
+			labels := m.renderSample(s)
+			values[labels] = s.value
+			labelSets = append(labelSets, labels)
+		}
+		sort.Strings(labelSets)
+		for _, labels := range labelSets {
+			if _, err := fmt.Fprintf(w, "%s%s %g\n", name, labels, values[labels]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ServeHTTP serves the metrics for scraping.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := m.Write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// WriteFile writes the metrics to path through a temporary file renamed in
+// place, so the textfile collector never reads a partial file.
+func (m *Metrics) WriteFile(path string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := m.Write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// stepMetrics records the duration and failures of every step.
+type stepMetrics struct {
+	metrics *Metrics
+	started map[string]time.Time
+}
+
+func (s *stepMetrics) StepStarted(name string) {
+	s.started[name] = time.Now()
+}
+
+func (s *stepMetrics) StepFinished(name string, o outcome, message string) {
+	if start, ok := s.started[name]; ok {
+		s.metrics.set(metricStepDuration, map[string]string{"step": name}, time.Since(start).Seconds())
+	}
+	if o == outcomeFailed {
+		s.metrics.add(metricStepFailures, map[string]string{"step": name}, 1)
+	}
+}
@@ -0,0 +1,122 @@
+package remover
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// DefaultNamespaceTimeout is how long the remover waits for a deleted
+// namespace to finish terminating before reporting what is blocking it.
+const DefaultNamespaceTimeout = 5 * time.Minute
+
+// namespacePollInterval is how often the namespace is checked while waiting.
+var namespacePollInterval = 5 * time.Second
+
+// namespaceStep removes a namespace and waits for it to finish terminating.
+type namespaceStep struct {
+	r         *Remover
+	name      string
+	namespace string
+}
+
+func (s *namespaceStep) Name() string {
+	return s.name
+}
+
+func (s *namespaceStep) Precondition() (bool, error) {
+	return true, nil
+}
+
+func (s *namespaceStep) Execute() error {
+	kubeClient := s.r.clients.Kube
+	s.r.reportTarget("Namespace", s.namespace, func() error {
+		_, err := kubeClient.CoreV1().Namespaces().Get(s.namespace, metav1.GetOptions{})
+		return err
+	})
+
+	err := s.r.deleteObject("Namespace", "", s.namespace, func(options *metav1.DeleteOptions) error {
+		return kubeClient.CoreV1().Namespaces().Delete(s.namespace, options)
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("problem removing target namespace [%s] :  %v", s.namespace, err)
+	}
+	s.r.reportDryRunResult("Namespace", s.namespace, err)
+	return nil
+}
+
+// Verify waits for the namespace to finish terminating, unless waiting is
+// disabled.
+func (s *namespaceStep) Verify() error {
+	if s.r.options.NamespaceTimeout <= 0 {
+		return nil
+	}
+	return s.r.waitForNamespaceDeletion(s.namespace, s.r.options.NamespaceTimeout)
+}
+
+// namespaceBlockingConditions are the namespace conditions the namespace
+// controller sets when it cannot finish removing the content of a namespace.
+var namespaceBlockingConditions = []corev1.NamespaceConditionType{
+	corev1.NamespaceDeletionDiscoveryFailure,
+	corev1.NamespaceDeletionGVParsingFailure,
+	corev1.NamespaceDeletionContentFailure,
+	corev1.NamespaceContentRemaining,
+	corev1.NamespaceFinalizersRemaining,
+}
+
+// waitForNamespaceDeletion waits until the namespace is gone or the timeout
+// passes. If the namespace is still terminating it logs the conditions and
+// finalizers that are holding it and returns an error.
+func (r *Remover) waitForNamespaceDeletion(name string, timeout time.Duration) error {
+	timeout = r.pollTimeout(timeout)
+	entry := log.WithFields(objectFields("Namespace", "", name))
+	entry.Infof("Waiting up to %v for the namespace to be removed", timeout)
+
+	var namespace *corev1.Namespace
+	err := r.poll(namespacePollInterval, timeout, func() (bool, error) {
+		ns, err := r.clients.Kube.CoreV1().Namespaces().Get(name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		} else if err != nil {
+			entry.WithError(err).Warning("problem getting the namespace")
+			return false, nil
+		}
+		namespace = ns
+		return false, nil
+	})
+	if err == nil {
+		entry.Info("Namespace has been removed")
+		return nil
+	} else if err != wait.ErrWaitTimeout {
+		return fmt.Errorf("namespace %s was not removed: %v", name, err)
+	}
+
+	entry.Errorf("namespace was not removed within %v", timeout)
+	if namespace != nil {
+		reportStuckNamespace(namespace)
+	}
+	return fmt.Errorf("namespace %s was not removed within %v", name, timeout)
+}
+
+// reportStuckNamespace logs the resources and finalizers that keep a namespace
+// in the Terminating phase.
+func reportStuckNamespace(namespace *corev1.Namespace) {
+	entry := log.WithFields(objectFields("Namespace", "", namespace.Name))
+	entry.Errorf("namespace is in phase %s", namespace.Status.Phase)
+	for _, finalizer := range namespace.Spec.Finalizers {
+		entry.Errorf("namespace still has finalizer %s", finalizer)
+	}
+	for _, conditionType := range namespaceBlockingConditions {
+		for _, condition := range namespace.Status.Conditions {
+			if condition.Type != conditionType || condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			entry.Errorf("namespace %s (%s): %s", condition.Type, condition.Reason, condition.Message)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package remover
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// DefaultOperandNamespace is the namespace the operator installs the Service
+// Catalog API server into.
+const DefaultOperandNamespace = "openshift-service-catalog-apiserver"
+
+var (
+	deploymentsResource = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	daemonSetsResource  = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}
+)
+
+// operandStopTimeout is how long the remover waits for the operand workloads
+// to stop, further bounded by the step and run timeouts.
+const operandStopTimeout = 2 * time.Minute
+
+// operandPollInterval is how often the operand workloads are checked while
+// waiting for them to stop.
+var operandPollInterval = 5 * time.Second
+
+// scaleDownOperandStep stops the Service Catalog API server workloads left in
+// the operand namespace, so the API server stops serving before its
+// APIService and namespace are removed. Deployments are scaled to zero and
+// DaemonSets, which cannot be scaled, are deleted.
+type scaleDownOperandStep struct {
+	r *Remover
+}
+
+func (s *scaleDownOperandStep) Name() string {
+	return "scale-down-operand"
+}
+
+// Precondition skips the step when the operand namespace does not exist.
+func (s *scaleDownOperandStep) Precondition() (bool, error) {
+	err := s.r.retry("get operand Namespace", func() error {
+		_, err := s.r.clients.Kube.CoreV1().Namespaces().Get(s.r.options.OperandNamespace, metav1.GetOptions{})
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		log.Infof("Operand namespace %s does not exist", s.r.options.OperandNamespace)
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("problem getting operand namespace [%s] :  %v", s.r.options.OperandNamespace, err)
+	}
+	return true, nil
+}
+
+func (s *scaleDownOperandStep) Execute() error {
+	namespace := s.r.options.OperandNamespace
+	var errs []error
+
+	deployments, err := s.r.listOperand(deploymentsResource)
+	if err != nil {
+		return err
+	}
+	patch := []byte(`{"spec":{"replicas":0}}`)
+	for _, deployment := range deployments {
+		name := deployment.GetName()
+		entry := log.WithFields(objectFields("Deployment", namespace, name))
+		if s.r.options.DryRun {
+			entry.Info("[dry-run] would be scaled down")
+		}
+		entry.Info("Scaling down")
+		err := s.r.retry(fmt.Sprintf("scale Deployment %s/%s", namespace, name), func() error {
+			_, err := s.r.clients.Dynamic.Resource(deploymentsResource).Namespace(namespace).Patch(name, types.MergePatchType, patch, s.r.patchOptions())
+			return err
+		})
+		switch {
+		case apierrors.IsNotFound(err):
+			s.r.results.add("Deployment", namespace, name, outcomeAlreadyAbsent, "")
+		case err != nil:
+			s.r.results.add("Deployment", namespace, name, outcomeFailed, err.Error())
+			errs = append(errs, fmt.Errorf("problem scaling down deployment [%s/%s] :  %v", namespace, name, err))
+		default:
+			s.r.results.add("Deployment", namespace, name, outcomeUpdated, "scaled to 0 replicas")
+		}
+	}
+
+	daemonSets, err := s.r.listOperand(daemonSetsResource)
+	if err != nil {
+		return err
+	}
+	for _, daemonSet := range daemonSets {
+		name := daemonSet.GetName()
+		if s.r.options.DryRun {
+			log.WithFields(objectFields("DaemonSet", namespace, name)).Info("[dry-run] exists and would be deleted")
+		}
+		err := s.r.deleteObject("DaemonSet", namespace, name, func(options *metav1.DeleteOptions) error {
+			return s.r.clients.Dynamic.Resource(daemonSetsResource).Namespace(namespace).Delete(name, options)
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("problem removing daemonset [%s/%s] :  %v", namespace, name, err))
+			continue
+		}
+		s.r.reportDryRunResult("DaemonSet", name, err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// Verify waits, up to operandStopTimeout, for the operand Deployments to report
+// no replicas and the DaemonSets to be gone.
+func (s *scaleDownOperandStep) Verify() error {
+	var remaining []string
+	check := func() (bool, error) {
+		remaining = nil
+		deployments, err := s.r.listOperand(deploymentsResource)
+		if err != nil {
+			return false, err
+		}
+		for _, deployment := range deployments {
+			spec, _, _ := unstructured.NestedInt64(deployment.Object, "spec", "replicas")
+			status, _, _ := unstructured.NestedInt64(deployment.Object, "status", "replicas")
+			if spec != 0 || status != 0 {
+				remaining = append(remaining, fmt.Sprintf("Deployment %s has %d replicas", deployment.GetName(), status))
+			}
+		}
+		daemonSets, err := s.r.listOperand(daemonSetsResource)
+		if err != nil {
+			return false, err
+		}
+		for _, daemonSet := range daemonSets {
+			remaining = append(remaining, fmt.Sprintf("DaemonSet %s still exists", daemonSet.GetName()))
+		}
+		return len(remaining) == 0, nil
+	}
+
+	if err := s.r.poll(operandPollInterval, operandStopTimeout, check); err != nil && err != wait.ErrWaitTimeout {
+		return err
+	}
+	if len(remaining) > 0 {
+		return fmt.Errorf("operand in namespace %s is still running: %v", s.r.options.OperandNamespace, remaining)
+	}
+	return nil
+}
+
+// listOperand lists the objects of a resource in the operand namespace.
+func (r *Remover) listOperand(resource schema.GroupVersionResource) ([]unstructured.Unstructured, error) {
+	var list *unstructured.UnstructuredList
+	err := r.retry(fmt.Sprintf("list %s in %s", resource.Resource, r.options.OperandNamespace), func() (err error) {
+		list, err = r.clients.Dynamic.Resource(resource).Namespace(r.options.OperandNamespace).List(metav1.ListOptions{})
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("problem listing %s in namespace [%s] :  %v", resource.Resource, r.options.OperandNamespace, err)
+	}
+	return list.Items, nil
+}
@@ -0,0 +1,111 @@
+package remover
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// customResourceStep removes the ServiceCatalogAPIServer CR.
+type customResourceStep struct {
+	r *Remover
+}
+
+func (s *customResourceStep) Name() string {
+	return "delete-custom-resource"
+}
+
+// Precondition skips the step when the CR has already been removed.
+func (s *customResourceStep) Precondition() (bool, error) {
+	err := s.r.retry("get ServiceCatalogAPIServer", func() error {
+		_, err := s.r.clients.Operator.OperatorV1().ServiceCatalogAPIServers().Get(s.r.options.CustomResourceName, metav1.GetOptions{})
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("problem getting ServiceCatalogAPIServer CR, error %v", err)
+	}
+	return true, nil
+}
+
+func (s *customResourceStep) Execute() error {
+	if s.r.options.DryRun {
+		log.WithFields(objectFields("ServiceCatalogAPIServer", "", s.r.options.CustomResourceName)).Info("[dry-run] exists and would be deleted")
+	}
+
+	err := s.r.deleteObject("ServiceCatalogAPIServer", "", s.r.options.CustomResourceName, func(options *metav1.DeleteOptions) error {
+		return s.r.clients.Operator.OperatorV1().ServiceCatalogAPIServers().Delete(s.r.options.CustomResourceName, options)
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("ServiceCatalogAPIServer cr deletion failed: %v", err)
+	}
+	s.r.reportDryRunResult("ServiceCatalogAPIServer", s.r.options.CustomResourceName, err)
+	return nil
+}
+
+func (s *customResourceStep) Verify() error {
+	return s.r.verifyAbsent("ServiceCatalogAPIServer", s.r.options.CustomResourceName, func() error {
+		_, err := s.r.clients.Operator.OperatorV1().ServiceCatalogAPIServers().Get(s.r.options.CustomResourceName, metav1.GetOptions{})
+		return err
+	})
+}
+
+// clusterOperatorStep removes the service-catalog-apiserver ClusterOperator.
+// It runs last so the ClusterOperator reports the progress of the teardown.
+type clusterOperatorStep struct {
+	r *Remover
+}
+
+func (s *clusterOperatorStep) Name() string {
+	return "delete-clusteroperator"
+}
+
+// Precondition keeps the ClusterOperator if any earlier step failed, so the
+// failure stays visible in its Degraded condition.
+func (s *clusterOperatorStep) Precondition() (bool, error) {
+	if s.r.results.Failed() {
+		log.Warningf("Keeping the %s clusteroperator since earlier steps failed", s.r.options.ClusterOperatorName)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (s *clusterOperatorStep) Execute() error {
+	configClient := s.r.clients.Config
+	s.r.reportTarget("ClusterOperator", s.r.options.ClusterOperatorName, func() error {
+		_, err := configClient.ConfigV1().ClusterOperators().Get(s.r.options.ClusterOperatorName, metav1.GetOptions{})
+		return err
+	})
+
+	err := s.r.deleteObject("ClusterOperator", "", s.r.options.ClusterOperatorName, func(options *metav1.DeleteOptions) error {
+		return configClient.ConfigV1().ClusterOperators().Delete(s.r.options.ClusterOperatorName, options)
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("problem removing cluster operator [%s] :  %v", s.r.options.ClusterOperatorName, err)
+	}
+	s.r.reportDryRunResult("ClusterOperator", s.r.options.ClusterOperatorName, err)
+	return nil
+}
+
+func (s *clusterOperatorStep) Verify() error {
+	return s.r.verifyAbsent("ClusterOperator", s.r.options.ClusterOperatorName, func() error {
+		_, err := s.r.clients.Config.ConfigV1().ClusterOperators().Get(s.r.options.ClusterOperatorName, metav1.GetOptions{})
+		return err
+	})
+}
+
+// verifyAbsent returns an error unless get reports that the object is gone.
+func (r *Remover) verifyAbsent(kind, name string, get func() error) error {
+	err := r.retry(fmt.Sprintf("get %s %s", kind, name), get)
+	switch {
+	case apierrors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return fmt.Errorf("problem getting %s [%s] :  %v", kind, name, err)
+	default:
+		return fmt.Errorf("%s [%s] is still present", kind, name)
+	}
+}
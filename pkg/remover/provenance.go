@@ -0,0 +1,259 @@
+package remover
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+var (
+	clusterServiceClassesResource = schema.GroupVersionResource{Group: serviceCatalogGroup, Version: "v1beta1", Resource: "clusterserviceclasses"}
+	serviceClassesResource        = schema.GroupVersionResource{Group: serviceCatalogGroup, Version: "v1beta1", Resource: "serviceclasses"}
+)
+
+// BindingSecretLabel is set to "true" on the secrets of ServiceBindings, so
+// they can still be found once Service Catalog is gone.
+const BindingSecretLabel = "servicecatalog.openshift.io/binding-secret"
+
+// Annotations recording where the credentials of a binding secret came from.
+const (
+	provenanceBindingName  = "servicecatalog.openshift.io/binding-name"
+	provenanceBindingUID   = "servicecatalog.openshift.io/binding-uid"
+	provenanceInstanceName = "servicecatalog.openshift.io/instance-name"
+	provenanceServiceClass = "servicecatalog.openshift.io/service-class"
+	provenanceServicePlan  = "servicecatalog.openshift.io/service-plan"
+	provenanceBroker       = "servicecatalog.openshift.io/broker"
+)
+
+// annotatedSecret is a secret patched with the provenance annotations.
+type annotatedSecret struct {
+	types.NamespacedName
+	annotations map[string]string
+}
+
+// annotateBindingSecretsStep annotates the secret of every ServiceBinding with
+// the binding, instance, class, plan and broker it was provisioned from.
+type annotateBindingSecretsStep struct {
+	r *Remover
+	// annotated lists the secrets patched by Execute.
+	annotated []annotatedSecret
+	// brokers caches the broker of each class by namespace and name.
+	brokers map[types.NamespacedName]string
+}
+
+func (s *annotateBindingSecretsStep) Name() string {
+	return "annotate-binding-secrets"
+}
+
+// Required stops the pipeline if any binding secret could not be annotated,
+// since the provenance cannot be read once the API server is removed.
+func (s *annotateBindingSecretsStep) Required() bool {
+	return true
+}
+
+func (s *annotateBindingSecretsStep) Precondition() (bool, error) {
+	return true, nil
+}
+
+func (s *annotateBindingSecretsStep) Execute() error {
+	log.Info("Annotating ServiceBinding secrets with their provenance")
+	s.brokers = map[types.NamespacedName]string{}
+	bindings, err := s.r.listAll(serviceBindingsResource)
+	if s.r.serviceCatalogAPIGone(err) {
+		log.Info("ServiceBinding API is not served by any service, no binding secrets to annotate.")
+		s.r.results.add("ServiceBinding", "", "", outcomeSkipped, "ServiceBinding API is not served")
+		return nil
+	} else if err != nil {
+		s.r.results.add("ServiceBinding", "", "", outcomeFailed, err.Error())
+		return err
+	}
+
+	var errs []error
+	for i := range bindings {
+		binding := &bindings[i]
+		namespace, secretName := binding.GetNamespace(), bindingSecretName(binding)
+		annotations, err := s.provenance(binding)
+		if err != nil {
+			s.r.results.add("Secret", namespace, secretName, outcomeFailed, err.Error())
+			errs = append(errs, fmt.Errorf("problem reading the provenance of ServiceBinding [%s/%s] :  %v", namespace, binding.GetName(), err))
+			continue
+		}
+
+		var o outcome
+		err = s.r.retry(fmt.Sprintf("update Secret %s/%s", namespace, secretName), func() (err error) {
+			o, err = s.annotate(namespace, secretName, annotations)
+			return err
+		})
+		switch {
+		case err != nil:
+			s.r.results.add("Secret", namespace, secretName, outcomeFailed, err.Error())
+			errs = append(errs, fmt.Errorf("problem annotating secret [%s/%s] :  %v", namespace, secretName, err))
+		case o == outcomeAlreadyAbsent:
+			log.WithFields(objectFields("Secret", namespace, secretName)).Info("Secret not found, skipping")
+		case o == outcomeUpdated:
+			s.r.results.add("Secret", namespace, secretName, outcomeUpdated, "provenance annotations added")
+			s.annotated = append(s.annotated, annotatedSecret{
+				NamespacedName: types.NamespacedName{Namespace: namespace, Name: secretName},
+				annotations:    annotations,
+			})
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// provenance returns the annotations describing where the credentials of the
+// binding came from. Whatever cannot be found is left out.
+func (s *annotateBindingSecretsStep) provenance(binding *unstructured.Unstructured) (map[string]string, error) {
+	annotations := map[string]string{
+		provenanceBindingName: binding.GetName(),
+		provenanceBindingUID:  string(binding.GetUID()),
+	}
+	instanceName, _, _ := unstructured.NestedString(binding.Object, "spec", "instanceRef", "name")
+	if instanceName == "" {
+		return annotations, nil
+	}
+	annotations[provenanceInstanceName] = instanceName
+
+	var instance *unstructured.Unstructured
+	err := s.r.retry(fmt.Sprintf("get ServiceInstance %s/%s", binding.GetNamespace(), instanceName), func() (err error) {
+		instance, err = s.r.clients.Dynamic.Resource(serviceInstancesResource).Namespace(binding.GetNamespace()).Get(instanceName, metav1.GetOptions{})
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		return annotations, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// An instance uses either a cluster wide or a namespaced class and plan.
+	spec := func(fields ...string) string {
+		for _, field := range fields {
+			if value, _, _ := unstructured.NestedString(instance.Object, "spec", field); value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+	if class := spec("clusterServiceClassExternalName", "serviceClassExternalName"); class != "" {
+		annotations[provenanceServiceClass] = class
+	}
+	if plan := spec("clusterServicePlanExternalName", "servicePlanExternalName"); plan != "" {
+		annotations[provenanceServicePlan] = plan
+	}
+
+	var broker string
+	if className, _, _ := unstructured.NestedString(instance.Object, "spec", "clusterServiceClassRef", "name"); className != "" {
+		broker, err = s.broker(clusterServiceClassesResource, "", className, "clusterServiceBrokerName")
+	} else if className, _, _ := unstructured.NestedString(instance.Object, "spec", "serviceClassRef", "name"); className != "" {
+		broker, err = s.broker(serviceClassesResource, instance.GetNamespace(), className, "serviceBrokerName")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if broker != "" {
+		annotations[provenanceBroker] = broker
+	}
+	return annotations, nil
+}
+
+// broker returns the name of the broker offering a class, empty if the class
+// is gone.
+func (s *annotateBindingSecretsStep) broker(gvr schema.GroupVersionResource, namespace, name, field string) (string, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	if broker, ok := s.brokers[key]; ok {
+		return broker, nil
+	}
+	var class *unstructured.Unstructured
+	err := s.r.retry(fmt.Sprintf("get %s %s", gvr.Resource, name), func() (err error) {
+		class, err = s.r.clients.Dynamic.Resource(gvr).Namespace(namespace).Get(name, metav1.GetOptions{})
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	broker, _, _ := unstructured.NestedString(class.Object, "spec", field)
+	s.brokers[key] = broker
+	return broker, nil
+}
+
+// annotate reads the secret and patches the provenance annotations and the
+// binding secret label onto it. It reports outcomeAlreadyAbsent if the secret
+// does not exist and outcomeSkipped if it is already annotated.
+func (s *annotateBindingSecretsStep) annotate(namespace, name string, annotations map[string]string) (outcome, error) {
+	secrets := s.r.clients.Dynamic.Resource(secretsResource).Namespace(namespace)
+	secret, err := secrets.Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return outcomeAlreadyAbsent, nil
+	} else if err != nil {
+		return outcomeFailed, err
+	}
+
+	current := secret.GetAnnotations()
+	changed := secret.GetLabels()[BindingSecretLabel] != "true"
+	for key, value := range annotations {
+		if current[key] != value {
+			changed = true
+		}
+	}
+	if !changed {
+		return outcomeSkipped, nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations":     annotations,
+			"labels":          map[string]string{BindingSecretLabel: "true"},
+			"resourceVersion": secret.GetResourceVersion(),
+		},
+	})
+	if err != nil {
+		return outcomeFailed, err
+	}
+	if _, err := secrets.Patch(name, types.MergePatchType, patch, s.r.patchOptions()); err != nil {
+		return outcomeFailed, err
+	}
+	return outcomeUpdated, nil
+}
+
+// Verify checks that the secrets patched by Execute carry the provenance
+// annotations and the binding secret label.
+func (s *annotateBindingSecretsStep) Verify() error {
+	var errs []error
+	for _, secret := range s.annotated {
+		namespace, name := secret.Namespace, secret.Name
+		var obj *unstructured.Unstructured
+		err := s.r.retry(fmt.Sprintf("get Secret %s/%s", namespace, name), func() (err error) {
+			obj, err = s.r.clients.Dynamic.Resource(secretsResource).Namespace(namespace).Get(name, metav1.GetOptions{})
+			return err
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("problem getting secret [%s/%s] :  %v", namespace, name, err))
+			continue
+		}
+		if obj.GetLabels()[BindingSecretLabel] != "true" {
+			errs = append(errs, fmt.Errorf("secret [%s/%s] is not labelled %s", namespace, name, BindingSecretLabel))
+		}
+		current := obj.GetAnnotations()
+		keys := make([]string, 0, len(secret.annotations))
+		for key := range secret.annotations {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if value := secret.annotations[key]; current[key] != value {
+				errs = append(errs, fmt.Errorf("secret [%s/%s] has annotation %s=%q, expected %q", namespace, name, key, current[key], value))
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
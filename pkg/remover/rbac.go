@@ -0,0 +1,72 @@
+package remover
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// clusterRoleStep removes the ClusterRole and ClusterRoleBinding of the
+// operator.
+type clusterRoleStep struct {
+	r *Remover
+}
+
+func (s *clusterRoleStep) Name() string {
+	return "delete-rbac"
+}
+
+func (s *clusterRoleStep) Precondition() (bool, error) {
+	return true, nil
+}
+
+func (s *clusterRoleStep) Execute() error {
+	kubeClient := s.r.clients.Kube
+	name := s.r.options.ClusterRoleName
+	var errs []error
+
+	s.r.reportTarget("ClusterRoleBinding", name, func() error {
+		_, err := kubeClient.RbacV1().ClusterRoleBindings().Get(name, metav1.GetOptions{})
+		return err
+	})
+
+	err := s.r.deleteObject("ClusterRoleBinding", "", name, func(options *metav1.DeleteOptions) error {
+		return kubeClient.RbacV1().ClusterRoleBindings().Delete(name, options)
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		errs = append(errs, fmt.Errorf("problem removing cluster role binding [%s] :  %v", name, err))
+	}
+	s.r.reportDryRunResult("ClusterRoleBinding", name, err)
+
+	s.r.reportTarget("ClusterRole", name, func() error {
+		_, err := kubeClient.RbacV1().ClusterRoles().Get(name, metav1.GetOptions{})
+		return err
+	})
+
+	err = s.r.deleteObject("ClusterRole", "", name, func(options *metav1.DeleteOptions) error {
+		return kubeClient.RbacV1().ClusterRoles().Delete(name, options)
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		errs = append(errs, fmt.Errorf("problem removing cluster role [%s] :  %v", name, err))
+	}
+	s.r.reportDryRunResult("ClusterRole", name, err)
+
+	return utilerrors.NewAggregate(errs)
+}
+
+func (s *clusterRoleStep) Verify() error {
+	kubeClient := s.r.clients.Kube
+	name := s.r.options.ClusterRoleName
+	return utilerrors.NewAggregate([]error{
+		s.r.verifyAbsent("ClusterRoleBinding", name, func() error {
+			_, err := kubeClient.RbacV1().ClusterRoleBindings().Get(name, metav1.GetOptions{})
+			return err
+		}),
+		s.r.verifyAbsent("ClusterRole", name, func() error {
+			_, err := kubeClient.RbacV1().ClusterRoles().Get(name, metav1.GetOptions{})
+			return err
+		}),
+	})
+}
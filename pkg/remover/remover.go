@@ -0,0 +1,479 @@
+package remover
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	operatorapiv1 "github.com/openshift/api/operator/v1"
+	configclient "github.com/openshift/client-go/config/clientset/versioned"
+	operatorclient "github.com/openshift/client-go/operator/clientset/versioned"
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+)
+
+// Exit codes reported by the remover job.
+const (
+	ExitSuccess        = 0
+	ExitPartialFailure = 1
+	// 2 is used by the flag package and by panics.
+	ExitManaged      = 3
+	ExitUnknownState = 4
+	ExitSetupFailure = 5
+	// ExitLiveResources means the teardown was refused because
+	// ServiceInstances or ServiceBindings still exist.
+	ExitLiveResources = 6
+	// ExitLeaseHeld means another remover run holds the Lease, or took it
+	// over during the run.
+	ExitLeaseHeld = 7
+	// ExitInterrupted means the run was stopped by a termination signal
+	// before it completed.
+	ExitInterrupted = 8
+)
+
+// Default names of the objects the remover targets.
+const (
+	DefaultNamespace           = "openshift-service-catalog-apiserver-operator"
+	DefaultCustomResourceName  = "cluster"
+	DefaultClusterOperatorName = "service-catalog-apiserver"
+	DefaultClusterRoleName     = "openshift-service-catalog-apiserver-operator"
+)
+
+// Options configures a remover run.
+type Options struct {
+	// DryRun makes the remover report what it would delete and submit every
+	// change with server-side dry-run instead of applying it.
+	DryRun bool
+	// NamespaceTimeout is how long to wait for a deleted namespace to finish
+	// terminating. Zero disables waiting.
+	NamespaceTimeout time.Duration
+	// RetryBackoff is applied to API calls failing with a transient error.
+	// Its Steps is the maximum number of attempts per call. The zero value
+	// selects DefaultRetryBackoff.
+	RetryBackoff wait.Backoff
+	// StepTimeout bounds the waits of every step. Zero means no limit.
+	StepTimeout time.Duration
+	// Timeout bounds the whole run. No step is started once it has passed.
+	// Zero means no limit.
+	Timeout time.Duration
+	// Force runs the teardown even if ServiceInstances or ServiceBindings
+	// still exist.
+	Force bool
+	// StripFinalizers removes the Service Catalog finalizer from
+	// ServiceInstances and ServiceBindings before the API server is removed.
+	StripFinalizers bool
+	// Steps restricts the run to the named steps. Empty runs every step.
+	Steps []string
+
+	// BackupTo is where the Service Catalog resources are archived before
+	// the teardown: BackupToNone, BackupToStdout, BackupToFile or
+	// BackupToConfigMap.
+	BackupTo string
+	// BackupPath is the file written when BackupTo is BackupToFile.
+	BackupPath string
+	// BackupFormat is BackupFormatYAML or BackupFormatJSON.
+	BackupFormat string
+
+	// ControllerManagerName is the name of the ServiceCatalogControllerManager
+	// CR.
+	ControllerManagerName string
+	// ControllerManagerNamespace is the namespace of the Service Catalog
+	// controller manager.
+	ControllerManagerNamespace string
+	// ControllerManagerTimeout is how long to wait for the controller manager
+	// to be removed. Zero or less disables waiting.
+	ControllerManagerTimeout time.Duration
+
+	// RemoverNamespace is the namespace the remover runs in and keeps its
+	// own state in.
+	RemoverNamespace string
+	// Identity is the holder identity of the Lease, unique to this run.
+	Identity string
+	// LeaseDuration is how long the Lease keeps other runs out if it is not
+	// renewed.
+	LeaseDuration time.Duration
+	// LeaseWait is how long to wait for another run to release the Lease. Zero
+	// refuses to run as soon as the Lease is found held.
+	LeaseWait time.Duration
+
+	// LogLevel overrides the log level of the CR, one of the log levels of
+	// the operator API. Empty uses the level of the CR.
+	LogLevel string
+
+	// ResetCheckpoint ignores the checkpoint of earlier runs and runs every
+	// selected step. The checkpoint is always ignored in dry-run mode.
+	ResetCheckpoint bool
+	// JobName is the name of the Job running the remover, the involved object
+	// of its events.
+	JobName string
+	// Namespace is the operator namespace to remove.
+	Namespace string
+	// OperandNamespace is the namespace the Service Catalog API server runs
+	// in.
+	OperandNamespace string
+	// LegacyNamespace is where older releases installed Service Catalog.
+	LegacyNamespace string
+	// CustomResourceName is the name of the ServiceCatalogAPIServer CR.
+	CustomResourceName string
+	// ClusterOperatorName is the name of the ClusterOperator to remove.
+	ClusterOperatorName string
+	// ClusterRoleName is the name of the operator's ClusterRole and
+	// ClusterRoleBinding.
+	ClusterRoleName string
+}
+
+// Clients holds the API clients used by the remover.
+type Clients struct {
+	Kube     kubernetes.Interface
+	Dynamic  dynamic.Interface
+	Operator operatorclient.Interface
+	Config   configclient.Interface
+	// Recorder records the events of the run. Nil disables events.
+	Recorder record.EventRecorder
+}
+
+// NewClients builds every client the remover needs from the given config.
+func NewClients(clientConfig *rest.Config) (*Clients, error) {
+	kubeClient, err := kubernetes.NewForConfig(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("problem getting kube client, error %v", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("problem getting dynamic client, error %v", err)
+	}
+	operatorClient, err := operatorclient.NewForConfig(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("problem getting operator client, error %v", err)
+	}
+	configClient, err := configclient.NewForConfig(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("problem getting config client, error %v", err)
+	}
+	return &Clients{Kube: kubeClient, Dynamic: dynamicClient, Operator: operatorClient, Config: configClient}, nil
+}
+
+// Remover tears down the Service Catalog API server resources.
+type Remover struct {
+	clients *Clients
+	options Options
+	results *ResultSet
+	metrics *Metrics
+	start   time.Time
+	// stopCh is closed by Stop.
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	// stepDeadline bounds the polls of the running step, zero if unbounded.
+	stepDeadline time.Time
+	// leaseLost is set to 1 by the Lease renewal once another run may hold
+	// the Lease.
+	leaseLost int32
+}
+
+// New returns a Remover using the given clients and options.
+func New(clients *Clients, options Options) *Remover {
+	if options.RetryBackoff.Steps == 0 {
+		options.RetryBackoff = DefaultRetryBackoff
+	}
+	if options.BackupTo == "" {
+		options.BackupTo = BackupToConfigMap
+	}
+	if options.BackupFormat == "" {
+		options.BackupFormat = BackupFormatYAML
+	}
+	if options.ControllerManagerName == "" {
+		options.ControllerManagerName = DefaultControllerManagerName
+	}
+	if options.ControllerManagerNamespace == "" {
+		options.ControllerManagerNamespace = DefaultControllerManagerNamespace
+	}
+	if options.RemoverNamespace == "" {
+		options.RemoverNamespace = DefaultRemoverNamespace
+	}
+	if options.Identity == "" {
+		options.Identity = defaultIdentity()
+	}
+	if options.LeaseDuration <= 0 {
+		options.LeaseDuration = DefaultLeaseDuration
+	}
+	if options.JobName == "" {
+		options.JobName = DefaultJobName
+	}
+	if options.Namespace == "" {
+		options.Namespace = DefaultNamespace
+	}
+	if options.OperandNamespace == "" {
+		options.OperandNamespace = DefaultOperandNamespace
+	}
+	if options.LegacyNamespace == "" {
+		options.LegacyNamespace = DefaultLegacyNamespace
+	}
+	if options.CustomResourceName == "" {
+		options.CustomResourceName = DefaultCustomResourceName
+	}
+	if options.ClusterOperatorName == "" {
+		options.ClusterOperatorName = DefaultClusterOperatorName
+	}
+	if options.ClusterRoleName == "" {
+		options.ClusterRoleName = DefaultClusterRoleName
+	}
+	return &Remover{
+		clients: clients,
+		options: options,
+		results: &ResultSet{dryRun: options.DryRun},
+		metrics: NewMetrics(),
+		start:   time.Now(),
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Metrics returns the metrics recorded so far.
+func (r *Remover) Metrics() *Metrics {
+	return r.metrics
+}
+
+// Results returns the results recorded so far.
+func (r *Remover) Results() *ResultSet {
+	return r.results
+}
+
+// teardownSteps returns the ordered steps that remove the Service Catalog API
+// server. The Service Catalog resources are backed up and the binding secrets
+// annotated and preserved first, since removing the API server makes them
+// unreachable. The controller manager has to be gone next, as it crash loops
+// without the API server and, while it runs, it adds back the finalizers the
+// following step strips. The operator is removed before the operand is scaled
+// down so it cannot scale it back up, and the operand stops serving before its
+// APIService and namespace go away, followed by whatever older installs left
+// behind. The ClusterOperator reports progress until it is removed last.
+func (r *Remover) teardownSteps() []Step {
+	return []Step{
+		&backupStep{r: r},
+		&annotateBindingSecretsStep{r: r},
+		&preserveBindingSecretsStep{r: r},
+		&controllerManagerStep{r: r},
+		&stripFinalizersStep{r: r},
+		&namespaceStep{r: r, name: "delete-operator-namespace", namespace: r.options.Namespace},
+		&scaleDownOperandStep{r: r},
+		&customResourceStep{r: r},
+		&apiServicesStep{r: r},
+		&namespaceStep{r: r, name: "delete-operand-namespace", namespace: r.options.OperandNamespace},
+		&namespaceStep{r: r, name: "delete-legacy-namespace", namespace: r.options.LegacyNamespace},
+		&legacyClusterResourcesStep{r: r},
+		&clusterRoleStep{r: r},
+		&clusterOperatorStep{r: r},
+	}
+}
+
+// Run inspects the ServiceCatalogAPIServer CR, runs the teardown if its
+// ManagementState allows it and returns the exit code for the run.
+func (r *Remover) Run() int {
+	code := r.run()
+	if code != ExitSuccess && r.stopped() {
+		code = ExitInterrupted
+	}
+	if r.lostLease() {
+		r.results.add("Lease", r.options.RemoverNamespace, LeaseName, outcomeFailed, "lease lost during the run")
+		code = ExitLeaseHeld
+	}
+	success := 0.0
+	if code == ExitSuccess {
+		success = 1
+	}
+	r.metrics.set(metricSuccess, nil, success)
+	return code
+}
+
+func (r *Remover) run() int {
+	if r.options.LogLevel != "" {
+		r.applyLogLevel(nil)
+	}
+	if r.options.DryRun {
+		log.Info("Running in dry-run mode, nothing will be deleted")
+	} else {
+		lock, err := r.acquireLease()
+		if err != nil {
+			log.Errorf("Not running: %v", err)
+			r.results.add("Lease", r.options.RemoverNamespace, LeaseName, outcomeFailed, err.Error())
+			if _, ok := err.(*errLeaseHeld); ok {
+				return ExitLeaseHeld
+			}
+			return ExitPartialFailure
+		}
+		defer lock.release()
+	}
+
+	var operatorConfig *operatorapiv1.ServiceCatalogAPIServer
+	err := r.retry("get ServiceCatalogAPIServer", func() (err error) {
+		operatorConfig, err = r.clients.Operator.OperatorV1().ServiceCatalogAPIServers().Get(r.options.CustomResourceName, metav1.GetOptions{})
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		log.Info("ServiceCatalogAPIServer cr has already been removed.")
+		r.metrics.setManagementState(branchNotFound)
+		return r.teardown(nil)
+	} else if err != nil {
+		r.metrics.setManagementState(branchError)
+		log.Errorf("problem getting ServiceCatalogAPIServer CR, error %v", err)
+		r.results.add("ServiceCatalogAPIServer", "", r.options.CustomResourceName, outcomeFailed, err.Error())
+		(&clusterOperatorStatus{r: r}).degraded(reasonCustomResourceError, fmt.Sprintf("Could not get ServiceCatalogAPIServer %s: %v", r.options.CustomResourceName, err))
+		return ExitPartialFailure
+	}
+
+	r.applyLogLevel(operatorConfig)
+
+	// Handle the various ManagementStates
+	r.metrics.setManagementState(string(operatorConfig.Spec.ManagementState))
+	switch operatorConfig.Spec.ManagementState {
+	case operatorapiv1.Managed:
+		log.Warning("We found a cluster-svcat-apiserver-operator in Managed state. Aborting")
+		if r.options.DryRun {
+			log.Info("[dry-run] a real run would not remove anything")
+		}
+		return ExitManaged
+	case operatorapiv1.Unmanaged:
+		log.Info("ServiceCatalogAPIServer managementState is 'Unmanaged'")
+		return r.teardown(operatorConfig)
+	case operatorapiv1.Removed:
+		log.Info("ServiceCatalogAPIServer managementState is 'Removed'")
+		return r.teardown(operatorConfig)
+	default:
+		log.Errorf("Unknown managementState %q", operatorConfig.Spec.ManagementState)
+		r.metrics.setManagementState(branchUnknown)
+		(&clusterOperatorStatus{r: r}).degraded(reasonUnknownManagementState, fmt.Sprintf("ServiceCatalogAPIServer %s has unknown managementState %q", r.options.CustomResourceName, operatorConfig.Spec.ManagementState))
+		return ExitUnknownState
+	}
+}
+
+// teardown runs the teardown steps unless live Service Catalog resources
+// block it. The CR is nil if it does not exist.
+func (r *Remover) teardown(operatorConfig *operatorapiv1.ServiceCatalogAPIServer) int {
+	if code, ok := r.checkLiveResources(operatorConfig); !ok {
+		return code
+	}
+	return r.runSteps(r.teardownSteps(), r.loadCheckpoint(operatorConfig))
+}
+
+// StepNames returns the names of the teardown steps in the order they run.
+func StepNames() []string {
+	var names []string
+	for _, step := range (&Remover{}).teardownSteps() {
+		names = append(names, step.Name())
+	}
+	return names
+}
+
+// ValidateSteps returns an error if any of the names is not a teardown step.
+func ValidateSteps(names []string) error {
+	known := sets.NewString(StepNames()...)
+	if unknown := sets.NewString(names...).Difference(known); unknown.Len() > 0 {
+		return fmt.Errorf("unknown steps %v, valid steps are %v", unknown.List(), StepNames())
+	}
+	return nil
+}
+
+// runSteps runs the selected steps through a pipeline and returns the exit
+// code. Steps not selected with Options.Steps or completed by an earlier run
+// are recorded as skipped.
+func (r *Remover) runSteps(steps []Step, checkpoint *checkpoint) int {
+	pipeline := NewPipeline(r.results, r.options.DryRun)
+	status := &clusterOperatorStatus{r: r}
+	pipeline.AddObserver(status)
+	pipeline.AddObserver(checkpoint)
+	pipeline.AddObserver(&stepTimeouts{r: r})
+	pipeline.AddObserver(stepLogging{})
+	pipeline.SetStop(r.stopCh)
+	pipeline.AddObserver(&stepMetrics{metrics: r.metrics, started: map[string]time.Time{}})
+	if r.clients.Recorder != nil && !r.options.DryRun {
+		pipeline.AddObserver(newStepEvents(r))
+	}
+	if r.options.Timeout > 0 {
+		pipeline.SetDeadline(r.start.Add(r.options.Timeout))
+	}
+	selected := sets.NewString(r.options.Steps...)
+	for _, step := range steps {
+		if selected.Len() > 0 && !selected.Has(step.Name()) {
+			r.results.addStep(step.Name(), outcomeSkipped, "not selected")
+			continue
+		}
+		if entry, ok := checkpoint.completed(step.Name()); ok {
+			log.Infof("Step %s was completed at %s, skipping", step.Name(), entry.Timestamp)
+			r.results.addStep(step.Name(), outcomeSkipped, fmt.Sprintf("completed by an earlier run at %s", entry.Timestamp))
+			continue
+		}
+		pipeline.Register(step)
+	}
+	status.start()
+	if err := pipeline.Run(); err != nil {
+		log.Error(err)
+	}
+	status.finish(r.results.Failed())
+	return r.results.ExitCode()
+}
+
+// deleteOptions returns the DeleteOptions used for every removal, requesting
+// server-side dry-run when running in dry-run mode so admission and RBAC
+// failures surface without deleting anything.
+func (r *Remover) deleteOptions() *metav1.DeleteOptions {
+	if r.options.DryRun {
+		return &metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}}
+	}
+	return &metav1.DeleteOptions{}
+}
+
+// patchOptions returns the PatchOptions used for every update, requesting
+// server-side dry-run when running in dry-run mode.
+func (r *Remover) patchOptions() metav1.PatchOptions {
+	if r.options.DryRun {
+		return metav1.PatchOptions{DryRun: []string{metav1.DryRunAll}}
+	}
+	return metav1.PatchOptions{}
+}
+
+// deleteObject deletes an object through del, retrying transient errors, and
+// records and logs the outcome.
+func (r *Remover) deleteObject(kind, namespace, name string, del func(*metav1.DeleteOptions) error) error {
+	log.WithFields(objectFields(kind, namespace, name)).Info("Removing")
+	start := time.Now()
+	err := r.retry(fmt.Sprintf("delete %s %s", kind, name), func() error {
+		return del(r.deleteOptions())
+	})
+	r.results.recordDelete(kind, namespace, name, err, time.Since(start))
+	if err == nil && !r.options.DryRun {
+		r.metrics.add(metricObjectsDeleted, map[string]string{"kind": kind}, 1)
+	}
+	return err
+}
+
+// reportTarget logs whether the object targeted for deletion currently exists.
+// It is only used in dry-run mode.
+func (r *Remover) reportTarget(kind, name string, get func() error) {
+	if !r.options.DryRun {
+		return
+	}
+	err := r.retry(fmt.Sprintf("get %s %s", kind, name), get)
+	entry := log.WithFields(objectFields(kind, "", name))
+	switch {
+	case err == nil:
+		entry.Info("[dry-run] exists and would be deleted")
+	case apierrors.IsNotFound(err):
+		entry.Info("[dry-run] does not exist, nothing to delete")
+	default:
+		entry.WithError(err).Error("[dry-run] problem looking up the object")
+	}
+}
+
+// reportDryRunResult logs the outcome of a server-side dry-run deletion.
+func (r *Remover) reportDryRunResult(kind, name string, err error) {
+	if !r.options.DryRun || err != nil {
+		return
+	}
+	log.WithFields(objectFields(kind, "", name)).Info("[dry-run] server accepted the deletion")
+}
@@ -0,0 +1,1784 @@
+package remover
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorapiv1 "github.com/openshift/api/operator/v1"
+	configfake "github.com/openshift/client-go/config/clientset/versioned/fake"
+	operatorfake "github.com/openshift/client-go/operator/clientset/versioned/fake"
+	log "github.com/sirupsen/logrus"
+	batchv1 "k8s.io/api/batch/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+// fakeClients holds the fake clientsets backing a Remover under test.
+type fakeClients struct {
+	kube     *kubefake.Clientset
+	dynamic  *dynamicfake.FakeDynamicClient
+	operator *operatorfake.Clientset
+	config   *configfake.Clientset
+	recorder *record.FakeRecorder
+}
+
+func (f *fakeClients) clients() *Clients {
+	return &Clients{Kube: f.kube, Dynamic: f.dynamic, Operator: f.operator, Config: f.config, Recorder: f.recorder}
+}
+
+// newFakeClients returns fake clientsets seeded with the objects the remover
+// acts on. A nil state means the ServiceCatalogAPIServer CR does not exist.
+func newFakeClients(state *operatorapiv1.ManagementState, dynamicObjects ...runtime.Object) *fakeClients {
+	var operatorObjects []runtime.Object
+	if state != nil {
+		operatorObjects = append(operatorObjects, &operatorapiv1.ServiceCatalogAPIServer{
+			ObjectMeta: metav1.ObjectMeta{Name: DefaultCustomResourceName},
+			Spec: operatorapiv1.ServiceCatalogAPIServerSpec{
+				OperatorSpec: operatorapiv1.OperatorSpec{ManagementState: *state},
+			},
+		})
+	}
+
+	dynamicObjects = append(dynamicObjects,
+		newAPIService("v1beta1.servicecatalog.k8s.io", serviceCatalogGroup),
+		newAPIService("v1.apps", "apps"),
+		newWorkload("Deployment", DefaultOperandNamespace, "apiserver"),
+		newWorkload("DaemonSet", DefaultOperandNamespace, "apiserver"),
+	)
+
+	return &fakeClients{
+		kube: kubefake.NewSimpleClientset(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: DefaultNamespace}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: DefaultOperandNamespace}},
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: DefaultClusterRoleName}},
+			&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: DefaultClusterRoleName}},
+		),
+		dynamic:  dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), dynamicObjects...),
+		operator: operatorfake.NewSimpleClientset(operatorObjects...),
+		config: configfake.NewSimpleClientset(
+			&configv1.ClusterOperator{ObjectMeta: metav1.ObjectMeta{Name: DefaultClusterOperatorName}},
+		),
+		recorder: record.NewFakeRecorder(1000),
+	}
+}
+
+func newAPIService(name, group string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apiregistration.k8s.io/v1",
+		"kind":       "APIService",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{"group": group},
+	}}
+}
+
+func newWorkload(kind, namespace, name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"namespace": namespace, "name": name},
+		"spec":       map[string]interface{}{"replicas": int64(1)},
+	}}
+}
+
+func newServiceBinding(namespace, name, secretName string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "servicecatalog.k8s.io/v1beta1",
+		"kind":       "ServiceBinding",
+		"metadata":   map[string]interface{}{"namespace": namespace, "name": name, "uid": name + "-uid"},
+		"spec":       map[string]interface{}{"secretName": secretName},
+	}}
+}
+
+func newBindingSecret(namespace, name, bindingName string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"namespace": namespace,
+			"name":      name,
+			"ownerReferences": []interface{}{
+				map[string]interface{}{
+					"apiVersion": "servicecatalog.k8s.io/v1beta1",
+					"kind":       "ServiceBinding",
+					"name":       bindingName,
+					"uid":        bindingName + "-uid",
+				},
+			},
+		},
+	}}
+}
+
+// serveServiceCatalog makes discovery report the servicecatalog.k8s.io API.
+func serveServiceCatalog(f *fakeClients) {
+	f.kube.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "servicecatalog.k8s.io/v1beta1",
+		APIResources: []metav1.APIResource{
+			{Name: "servicebindings", Kind: "ServiceBinding", Namespaced: true, Verbs: []string{"get", "list", "delete"}},
+			{Name: "servicebindings/status", Kind: "ServiceBinding", Namespaced: true, Verbs: []string{"get", "update"}},
+			{Name: "clusterservicebrokers", Kind: "ClusterServiceBroker", Verbs: []string{"get", "list", "delete"}},
+		},
+	}}
+}
+
+// testBackoff retries quickly so injected transient errors do not slow the
+// tests down.
+var testBackoff = wait.Backoff{Duration: time.Millisecond, Factor: 1.0, Steps: 3}
+
+func managementState(state operatorapiv1.ManagementState) *operatorapiv1.ManagementState {
+	return &state
+}
+
+// failOn makes every verb on resource fail with an internal error.
+func failOn(verb, resource string) clienttesting.ReactionFunc {
+	return func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetVerb() != verb || action.GetResource().Resource != resource {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewInternalError(fmt.Errorf("injected %s %s failure", verb, resource))
+	}
+}
+
+// dryRunDynamic emulates server-side dry-run on top of the fake dynamic
+// client, which ignores it: deletes and patches requesting dry-run only check
+// that the object exists.
+type dryRunDynamic struct {
+	dynamic.Interface
+}
+
+func (d dryRunDynamic) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return dryRunResource{d.Interface.Resource(resource)}
+}
+
+type dryRunResource struct {
+	dynamic.NamespaceableResourceInterface
+}
+
+func (r dryRunResource) Namespace(namespace string) dynamic.ResourceInterface {
+	return dryRunNamespacedResource{r.NamespaceableResourceInterface.Namespace(namespace)}
+}
+
+func (r dryRunResource) Delete(name string, options *metav1.DeleteOptions, subresources ...string) error {
+	return dryRunDelete(r.NamespaceableResourceInterface, name, options, subresources...)
+}
+
+func (r dryRunResource) Patch(name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return dryRunPatch(r.NamespaceableResourceInterface, name, pt, data, options, subresources...)
+}
+
+type dryRunNamespacedResource struct {
+	dynamic.ResourceInterface
+}
+
+func (r dryRunNamespacedResource) Delete(name string, options *metav1.DeleteOptions, subresources ...string) error {
+	return dryRunDelete(r.ResourceInterface, name, options, subresources...)
+}
+
+func (r dryRunNamespacedResource) Patch(name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return dryRunPatch(r.ResourceInterface, name, pt, data, options, subresources...)
+}
+
+func dryRunDelete(client dynamic.ResourceInterface, name string, options *metav1.DeleteOptions, subresources ...string) error {
+	if options == nil || len(options.DryRun) == 0 {
+		return client.Delete(name, options, subresources...)
+	}
+	_, err := client.Get(name, metav1.GetOptions{}, subresources...)
+	return err
+}
+
+func dryRunPatch(client dynamic.ResourceInterface, name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	if len(options.DryRun) == 0 {
+		return client.Patch(name, pt, data, options, subresources...)
+	}
+	return client.Get(name, metav1.GetOptions{}, subresources...)
+}
+
+// unavailableOn fails the matching requests like an aggregated API whose
+// service is down.
+func unavailableOn(verb, resource string) clienttesting.ReactionFunc {
+	return func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetVerb() != verb || action.GetResource().Resource != resource {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewServiceUnavailable("the server is currently unable to handle the request")
+	}
+}
+
+// unavailableTimes fails the first n matching requests like an aggregated API
+// server that is restarting.
+func unavailableTimes(verb, resource string, n int) clienttesting.ReactionFunc {
+	failed := 0
+	return func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetVerb() != verb || action.GetResource().Resource != resource || failed >= n {
+			return false, nil, nil
+		}
+		failed++
+		return true, nil, apierrors.NewServiceUnavailable("the server is currently unable to handle the request")
+	}
+}
+
+// serveFromService points the Service Catalog APIService at a service in the
+// operand namespace, which only exists if serviceExists is set.
+func serveFromService(t *testing.T, f *fakeClients, serviceExists bool) {
+	t.Helper()
+	apiServices := f.dynamic.Resource(apiServicesResource)
+	apiService, err := apiServices.Get("v1beta1.servicecatalog.k8s.io", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := map[string]interface{}{"namespace": DefaultOperandNamespace, "name": "apiserver"}
+	if err := unstructured.SetNestedMap(apiService.Object, service, "spec", "service"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := apiServices.Update(apiService, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if serviceExists {
+		if err := f.kube.Tracker().Add(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: DefaultOperandNamespace, Name: "apiserver"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// remaining returns which of the objects the remover targets still exist. It
+// reads the object trackers directly where possible so injected failures do not
+// interfere.
+func remaining(t *testing.T, f *fakeClients) map[string]bool {
+	exists := func(tracker clienttesting.ObjectTracker, gvr schema.GroupVersionResource, name string) bool {
+		var err error
+		if tracker != nil {
+			_, err = tracker.Get(gvr, "", name)
+		} else {
+			_, err = f.dynamic.Resource(gvr).Get(name, metav1.GetOptions{})
+		}
+		if err != nil && !apierrors.IsNotFound(err) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return err == nil
+	}
+	return map[string]bool{
+		"Namespace":               exists(f.kube.Tracker(), corev1.SchemeGroupVersion.WithResource("namespaces"), DefaultNamespace),
+		"OperandNamespace":        exists(f.kube.Tracker(), corev1.SchemeGroupVersion.WithResource("namespaces"), DefaultOperandNamespace),
+		"ServiceCatalogAPIServer": exists(f.operator.Tracker(), operatorapiv1.GroupVersion.WithResource("servicecatalogapiservers"), DefaultCustomResourceName),
+		"ClusterOperator":         exists(f.config.Tracker(), configv1.GroupVersion.WithResource("clusteroperators"), DefaultClusterOperatorName),
+		"ClusterRoleBinding":      exists(f.kube.Tracker(), rbacv1.SchemeGroupVersion.WithResource("clusterrolebindings"), DefaultClusterRoleName),
+		"ClusterRole":             exists(f.kube.Tracker(), rbacv1.SchemeGroupVersion.WithResource("clusterroles"), DefaultClusterRoleName),
+		"APIService":              exists(nil, apiServicesResource, "v1beta1.servicecatalog.k8s.io"),
+	}
+}
+
+func allTargets(exist bool) map[string]bool {
+	return map[string]bool{
+		"Namespace":               exist,
+		"OperandNamespace":        exist,
+		"ServiceCatalogAPIServer": exist,
+		"ClusterOperator":         exist,
+		"ClusterRoleBinding":      exist,
+		"ClusterRole":             exist,
+		"APIService":              exist,
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name            string
+		state           *operatorapiv1.ManagementState
+		options         Options
+		setup           func(f *fakeClients)
+		expectedCode    int
+		expectDegraded  bool
+		expectRemaining map[string]bool
+	}{
+		{
+			name:            "custom resource already removed",
+			expectedCode:    ExitSuccess,
+			expectRemaining: allTargets(false),
+		},
+		{
+			name:            "managed aborts",
+			state:           managementState(operatorapiv1.Managed),
+			expectedCode:    ExitManaged,
+			expectRemaining: allTargets(true),
+		},
+		{
+			name:            "unmanaged removes everything",
+			state:           managementState(operatorapiv1.Unmanaged),
+			expectedCode:    ExitSuccess,
+			expectRemaining: allTargets(false),
+		},
+		{
+			name:            "removed removes everything",
+			state:           managementState(operatorapiv1.Removed),
+			expectedCode:    ExitSuccess,
+			expectRemaining: allTargets(false),
+		},
+		{
+			name:            "unknown management state",
+			state:           managementState("Bogus"),
+			expectedCode:    ExitUnknownState,
+			expectDegraded:  true,
+			expectRemaining: allTargets(true),
+		},
+		{
+			name:  "error getting the custom resource",
+			state: managementState(operatorapiv1.Removed),
+			setup: func(f *fakeClients) {
+				f.operator.PrependReactor("get", "servicecatalogapiservers", failOn("get", "servicecatalogapiservers"))
+			},
+			expectedCode:    ExitPartialFailure,
+			expectDegraded:  true,
+			expectRemaining: allTargets(true),
+		},
+		{
+			name:  "partial failure keeps removing the other objects",
+			state: managementState(operatorapiv1.Removed),
+			setup: func(f *fakeClients) {
+				f.kube.PrependReactor("delete", "clusterroles", failOn("delete", "clusterroles"))
+			},
+			expectedCode:   ExitPartialFailure,
+			expectDegraded: true,
+			expectRemaining: map[string]bool{
+				"Namespace":               false,
+				"ServiceCatalogAPIServer": false,
+				"ClusterOperator":         true,
+				"ClusterRoleBinding":      false,
+				"ClusterRole":             true,
+				"APIService":              false,
+			},
+		},
+		{
+			name:  "failing to preserve binding secrets stops the teardown",
+			state: managementState(operatorapiv1.Removed),
+			setup: func(f *fakeClients) {
+				f.dynamic.PrependReactor("list", "servicebindings", failOn("list", "servicebindings"))
+			},
+			expectedCode:    ExitPartialFailure,
+			expectRemaining: allTargets(true),
+		},
+		{
+			name:  "namespace stuck terminating",
+			state: managementState(operatorapiv1.Removed),
+			options: Options{
+				NamespaceTimeout: 10 * time.Millisecond,
+			},
+			setup: func(f *fakeClients) {
+				f.kube.PrependReactor("delete", "namespaces", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, nil, nil
+				})
+			},
+			expectedCode:   ExitPartialFailure,
+			expectDegraded: true,
+			expectRemaining: map[string]bool{
+				"Namespace":               true,
+				"ServiceCatalogAPIServer": false,
+				"ClusterOperator":         true,
+				"ClusterRoleBinding":      false,
+				"ClusterRole":             false,
+				"APIService":              false,
+			},
+		},
+		{
+			name:         "only the selected steps run",
+			state:        managementState(operatorapiv1.Removed),
+			options:      Options{Steps: []string{"delete-rbac"}},
+			expectedCode: ExitSuccess,
+			expectRemaining: map[string]bool{
+				"Namespace":               true,
+				"ServiceCatalogAPIServer": true,
+				"ClusterOperator":         true,
+				"ClusterRoleBinding":      false,
+				"ClusterRole":             false,
+				"APIService":              true,
+			},
+		},
+		{
+			name:            "no step starts after the timeout",
+			state:           managementState(operatorapiv1.Removed),
+			options:         Options{Timeout: time.Nanosecond},
+			expectedCode:    ExitPartialFailure,
+			expectRemaining: allTargets(true),
+		},
+		{
+			name:            "dry-run removes nothing",
+			state:           managementState(operatorapiv1.Removed),
+			options:         Options{DryRun: true},
+			expectedCode:    ExitSuccess,
+			expectRemaining: allTargets(true),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.options.RetryBackoff = testBackoff
+			f := newFakeClients(tc.state)
+			if tc.setup != nil {
+				tc.setup(f)
+			}
+			clients := f.clients()
+			if tc.options.DryRun {
+				// The fake clientsets ignore DryRun, so emulate the server.
+				// The typed clients cannot tell dry-run requests apart, which
+				// TestDryRunOptions covers instead.
+				for _, c := range []*clienttesting.Fake{&f.kube.Fake, &f.operator.Fake, &f.config.Fake} {
+					c.PrependReactor("delete", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+						return true, nil, nil
+					})
+				}
+				clients.Dynamic = dryRunDynamic{f.dynamic}
+			}
+
+			code := New(clients, tc.options).Run()
+			if code != tc.expectedCode {
+				t.Errorf("expected exit code %d, got %d", tc.expectedCode, code)
+			}
+			got := remaining(t, f)
+			for kind, expected := range tc.expectRemaining {
+				if got[kind] != expected {
+					t.Errorf("expected %s present=%v, got %v", kind, expected, got[kind])
+				}
+			}
+			if _, err := f.dynamic.Resource(apiServicesResource).Get("v1.apps", metav1.GetOptions{}); err != nil {
+				t.Errorf("unrelated APIService was touched: %v", err)
+			}
+			if tc.expectDegraded {
+				expectCondition(t, f, configv1.OperatorDegraded, configv1.ConditionTrue)
+				expectCondition(t, f, configv1.OperatorProgressing, configv1.ConditionFalse)
+			}
+			if tc.options.DryRun {
+				deployment, err := f.dynamic.Resource(deploymentsResource).Namespace(DefaultOperandNamespace).Get("apiserver", metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if replicas, _, _ := unstructured.NestedInt64(deployment.Object, "spec", "replicas"); replicas != 1 {
+					t.Errorf("expected dry-run to keep the operand deployment at 1 replica, got %d", replicas)
+				}
+				if _, err := f.dynamic.Resource(daemonSetsResource).Namespace(DefaultOperandNamespace).Get("apiserver", metav1.GetOptions{}); err != nil {
+					t.Errorf("expected dry-run to keep the operand daemonset: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestDryRunOptions(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		r := New(newFakeClients(nil).clients(), Options{RetryBackoff: testBackoff, DryRun: dryRun})
+		var expected []string
+		if dryRun {
+			expected = []string{metav1.DryRunAll}
+		}
+
+		if got := r.deleteOptions().DryRun; !reflect.DeepEqual(got, expected) {
+			t.Errorf("dry-run %v: expected delete options DryRun %v, got %v", dryRun, expected, got)
+		}
+		if got := r.patchOptions().DryRun; !reflect.DeepEqual(got, expected) {
+			t.Errorf("dry-run %v: expected patch options DryRun %v, got %v", dryRun, expected, got)
+		}
+
+		var passed *metav1.DeleteOptions
+		err := r.deleteObject("ClusterRole", "", DefaultClusterRoleName, func(options *metav1.DeleteOptions) error {
+			passed = options
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if passed == nil || !reflect.DeepEqual(passed.DryRun, expected) {
+			t.Errorf("dry-run %v: expected the delete callback to get DryRun %v, got %v", dryRun, expected, passed)
+		}
+	}
+}
+
+// expectCondition checks the status of a condition of the ClusterOperator.
+func expectCondition(t *testing.T, f *fakeClients, conditionType configv1.ClusterStatusConditionType, status configv1.ConditionStatus) {
+	t.Helper()
+	clusterOperator, err := f.config.ConfigV1().ClusterOperators().Get(DefaultClusterOperatorName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, condition := range clusterOperator.Status.Conditions {
+		if condition.Type == conditionType {
+			if condition.Status != status {
+				t.Errorf("expected %s=%s, got %s: %s", conditionType, status, condition.Status, condition.Message)
+			}
+			return
+		}
+	}
+	t.Errorf("expected %s=%s, condition not set", conditionType, status)
+}
+
+func TestClusterOperatorStatus(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed))
+	r := New(f.clients(), Options{RetryBackoff: testBackoff, Steps: []string{"delete-rbac"}})
+	if code := r.Run(); code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d", ExitSuccess, code)
+	}
+
+	expectCondition(t, f, configv1.OperatorProgressing, configv1.ConditionFalse)
+	expectCondition(t, f, configv1.OperatorDegraded, configv1.ConditionFalse)
+	clusterOperator, err := f.config.ConfigV1().ClusterOperators().Get(DefaultClusterOperatorName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if related := clusterOperator.Status.RelatedObjects; !reflect.DeepEqual(related, (&clusterOperatorStatus{r: r}).relatedObjects()) {
+		t.Errorf("unexpected related objects %v", related)
+	}
+}
+
+func TestClusterRoleName(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed))
+	f.kube.Tracker().Add(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "custom"}})
+	f.kube.Tracker().Add(&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "custom"}})
+	r := New(f.clients(), Options{RetryBackoff: testBackoff, ClusterRoleName: "custom", Steps: []string{"delete-rbac"}})
+	if code := r.Run(); code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d", ExitSuccess, code)
+	}
+
+	for _, resource := range []string{"clusterroles", "clusterrolebindings"} {
+		gvr := rbacv1.SchemeGroupVersion.WithResource(resource)
+		if _, err := f.kube.Tracker().Get(gvr, "", "custom"); !apierrors.IsNotFound(err) {
+			t.Errorf("expected the configured %s to be removed, got %v", resource, err)
+		}
+		if _, err := f.kube.Tracker().Get(gvr, "", DefaultClusterRoleName); err != nil {
+			t.Errorf("expected the default %s to be left alone, got %v", resource, err)
+		}
+	}
+	clusterOperator, err := f.config.ConfigV1().ClusterOperators().Get(DefaultClusterOperatorName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, related := range clusterOperator.Status.RelatedObjects {
+		if related.Group == rbacv1.GroupName && related.Name != "custom" {
+			t.Errorf("expected related %s to be named custom, got %q", related.Resource, related.Name)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed))
+	f.kube.PrependReactor("delete", "clusterroles", failOn("delete", "clusterroles"))
+	New(f.clients(), Options{RetryBackoff: testBackoff, Steps: []string{"delete-rbac"}}).Run()
+	close(f.recorder.Events)
+
+	var events []string
+	for event := range f.recorder.Events {
+		events = append(events, event)
+	}
+	for _, want := range []string{
+		"Normal StepStarted Running step delete-rbac",
+		"Warning StepFailed Step delete-rbac failed",
+	} {
+		found := false
+		for _, event := range events {
+			if strings.HasPrefix(event, want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected an event %q, got %v", want, events)
+		}
+	}
+}
+
+// referenceRecorder records the objects events are recorded against.
+type referenceRecorder struct {
+	*record.FakeRecorder
+	references []*corev1.ObjectReference
+}
+
+func (r *referenceRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.references = append(r.references, object.(*corev1.ObjectReference))
+	r.FakeRecorder.Event(object, eventtype, reason, message)
+}
+
+func (r *referenceRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.references = append(r.references, object.(*corev1.ObjectReference))
+	r.FakeRecorder.Eventf(object, eventtype, reason, messageFmt, args...)
+}
+
+func TestEventsReferenceJob(t *testing.T) {
+	for _, jobExists := range []bool{true, false} {
+		t.Run(fmt.Sprintf("exists=%v", jobExists), func(t *testing.T) {
+			f := newFakeClients(managementState(operatorapiv1.Removed))
+			if jobExists {
+				f.kube.Tracker().Add(&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: DefaultRemoverNamespace, Name: DefaultJobName, UID: "job-uid"}})
+			}
+			recorder := &referenceRecorder{FakeRecorder: f.recorder}
+			clients := f.clients()
+			clients.Recorder = recorder
+			if code := New(clients, Options{RetryBackoff: testBackoff, Steps: []string{"delete-rbac"}}).Run(); code != ExitSuccess {
+				t.Fatalf("expected exit code %d, got %d", ExitSuccess, code)
+			}
+
+			var expected types.UID
+			if jobExists {
+				expected = "job-uid"
+			}
+			if len(recorder.references) == 0 {
+				t.Fatal("expected events")
+			}
+			for _, reference := range recorder.references {
+				if reference.Kind != "Job" || reference.Namespace != DefaultRemoverNamespace || reference.Name != DefaultJobName || reference.UID != expected {
+					t.Errorf("expected events on job %s/%s with uid %q, got %+v", DefaultRemoverNamespace, DefaultJobName, expected, reference)
+				}
+			}
+		})
+	}
+}
+
+// stepOutcomes returns the outcome recorded for every step of a run.
+func stepOutcomes(r *Remover) map[string]outcome {
+	outcomes := map[string]outcome{}
+	for _, step := range r.Results().steps {
+		outcomes[step.name] = step.outcome
+	}
+	return outcomes
+}
+
+func TestCheckpoint(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed))
+	f.kube.PrependReactor("delete", "clusterroles", failOn("delete", "clusterroles"))
+	first := New(f.clients(), Options{RetryBackoff: testBackoff})
+	if code := first.Run(); code != ExitPartialFailure {
+		t.Fatalf("first run: expected exit code %d, got %d", ExitPartialFailure, code)
+	}
+
+	configMap, err := f.kube.CoreV1().ConfigMaps(DefaultRemoverNamespace).Get(CheckpointName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := configMap.Data["delete-rbac"]; !strings.Contains(entry, `"outcome":"failed"`) || !strings.Contains(entry, "injected delete clusterroles failure") {
+		t.Errorf("expected the failure of delete-rbac to be recorded, got %q", entry)
+	}
+
+	f.kube.ReactionChain = f.kube.ReactionChain[1:]
+	second := New(f.clients(), Options{RetryBackoff: testBackoff})
+	if code := second.Run(); code != ExitSuccess {
+		t.Fatalf("second run: expected exit code %d, got %d", ExitSuccess, code)
+	}
+	outcomes := stepOutcomes(second)
+	if outcomes["delete-apiservices"] != outcomeSkipped {
+		t.Errorf("expected the completed delete-apiservices step to be skipped, got %s", outcomes["delete-apiservices"])
+	}
+	if outcomes["delete-rbac"] != outcomeCompleted {
+		t.Errorf("expected the failed delete-rbac step to run again, got %s", outcomes["delete-rbac"])
+	}
+	if outcomes["delete-clusteroperator"] != outcomeCompleted {
+		t.Errorf("expected the delete-clusteroperator step to run, got %s", outcomes["delete-clusteroperator"])
+	}
+	if got := remaining(t, f); !reflect.DeepEqual(got, allTargets(false)) {
+		t.Errorf("expected every object to be removed, got %v", got)
+	}
+}
+
+func TestCheckpointOfAnotherCustomResource(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed))
+	err := f.kube.Tracker().Add(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: DefaultRemoverNamespace, Name: CheckpointName},
+		Data: map[string]string{
+			checkpointUIDKey: "old-uid",
+			"delete-rbac":    `{"outcome":"completed","timestamp":"2020-01-01T00:00:00Z"}`,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr, err := f.operator.OperatorV1().ServiceCatalogAPIServers().Get(DefaultCustomResourceName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr.UID = "new-uid"
+	if _, err := f.operator.OperatorV1().ServiceCatalogAPIServers().Update(cr); err != nil {
+		t.Fatal(err)
+	}
+
+	r := New(f.clients(), Options{RetryBackoff: testBackoff, Steps: []string{"delete-rbac"}})
+	if code := r.Run(); code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d", ExitSuccess, code)
+	}
+	if outcome := stepOutcomes(r)["delete-rbac"]; outcome != outcomeCompleted {
+		t.Errorf("expected delete-rbac to run despite the stale checkpoint, got %s", outcome)
+	}
+}
+
+func TestCheckpointIgnoredInDryRun(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed))
+	err := f.kube.Tracker().Add(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: DefaultRemoverNamespace, Name: CheckpointName},
+		Data: map[string]string{
+			"delete-rbac": `{"outcome":"completed","timestamp":"2020-01-01T00:00:00Z"}`,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := New(f.clients(), Options{RetryBackoff: testBackoff, DryRun: true, Steps: []string{"delete-rbac"}})
+	if code := r.Run(); code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d", ExitSuccess, code)
+	}
+	if outcome := stepOutcomes(r)["delete-rbac"]; outcome != outcomeCompleted {
+		t.Errorf("expected delete-rbac to be previewed despite the checkpoint, got %s", outcome)
+	}
+	configMap, err := f.kube.CoreV1().ConfigMaps(DefaultRemoverNamespace).Get(CheckpointName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry := configMap.Data["delete-rbac"]; !strings.Contains(entry, "2020-01-01T00:00:00Z") {
+		t.Errorf("expected the checkpoint to be left alone, got %q", entry)
+	}
+}
+
+func TestRunIsIdempotent(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed))
+
+	if code := New(f.clients(), Options{RetryBackoff: testBackoff}).Run(); code != ExitSuccess {
+		t.Fatalf("first run: expected exit code %d, got %d", ExitSuccess, code)
+	}
+
+	r := New(f.clients(), Options{RetryBackoff: testBackoff})
+	if code := r.Run(); code != ExitSuccess {
+		t.Fatalf("second run: expected exit code %d, got %d", ExitSuccess, code)
+	}
+	for _, res := range r.Results().results {
+		if res.outcome == outcomeDeleted {
+			t.Errorf("second run deleted %s %s", res.kind, res.name)
+		}
+	}
+}
+
+func TestPreserveBindingSecrets(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed),
+		newServiceBinding("app", "db-binding", "db-secret"),
+		newBindingSecret("app", "db-secret", "db-binding"),
+		newServiceBinding("app", "missing-binding", "missing-secret"),
+		newServiceBinding("other", "plain-binding", ""),
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"namespace": "other", "name": "plain-binding"},
+		}},
+	)
+
+	if code := New(f.clients(), Options{RetryBackoff: testBackoff, Force: true}).Run(); code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d", ExitSuccess, code)
+	}
+
+	secrets := f.dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "secrets"})
+	secret, err := secrets.Namespace("app").Get("db-secret", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owners := secret.GetOwnerReferences(); len(owners) != 0 {
+		t.Errorf("expected servicecatalog owner references to be removed, got %v", owners)
+	}
+}
+
+func TestAnnotateBindingSecrets(t *testing.T) {
+	binding := newServiceBinding("app", "db-binding", "db-secret")
+	binding.Object["spec"].(map[string]interface{})["instanceRef"] = map[string]interface{}{"name": "db"}
+	f := newFakeClients(managementState(operatorapiv1.Removed),
+		binding,
+		newBindingSecret("app", "db-secret", "db-binding"),
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "servicecatalog.k8s.io/v1beta1",
+			"kind":       "ServiceInstance",
+			"metadata":   map[string]interface{}{"namespace": "app", "name": "db"},
+			"spec": map[string]interface{}{
+				"clusterServiceClassExternalName": "postgresql",
+				"clusterServicePlanExternalName":  "small",
+				"clusterServiceClassRef":          map[string]interface{}{"name": "postgresql-class"},
+			},
+		}},
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "servicecatalog.k8s.io/v1beta1",
+			"kind":       "ClusterServiceClass",
+			"metadata":   map[string]interface{}{"name": "postgresql-class"},
+			"spec":       map[string]interface{}{"clusterServiceBrokerName": "template-service-broker"},
+		}},
+		newServiceBinding("other", "orphan-binding", "orphan-secret"),
+		newBindingSecret("other", "orphan-secret", "orphan-binding"),
+	)
+
+	r := New(f.clients(), Options{RetryBackoff: testBackoff, Force: true})
+	if code := r.Run(); code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d", ExitSuccess, code)
+	}
+	if outcome := stepOutcomes(r)["annotate-binding-secrets"]; outcome != outcomeCompleted {
+		t.Errorf("expected annotate-binding-secrets to succeed, got %s", outcome)
+	}
+
+	secrets := f.dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "secrets"})
+	for _, tc := range []struct {
+		namespace, name string
+		annotations     map[string]string
+	}{
+		{
+			namespace: "app",
+			name:      "db-secret",
+			annotations: map[string]string{
+				"servicecatalog.openshift.io/binding-name":  "db-binding",
+				"servicecatalog.openshift.io/binding-uid":   "db-binding-uid",
+				"servicecatalog.openshift.io/instance-name": "db",
+				"servicecatalog.openshift.io/service-class": "postgresql",
+				"servicecatalog.openshift.io/service-plan":  "small",
+				"servicecatalog.openshift.io/broker":        "template-service-broker",
+			},
+		},
+		{
+			namespace: "other",
+			name:      "orphan-secret",
+			annotations: map[string]string{
+				"servicecatalog.openshift.io/binding-name": "orphan-binding",
+				"servicecatalog.openshift.io/binding-uid":  "orphan-binding-uid",
+			},
+		},
+	} {
+		secret, err := secrets.Namespace(tc.namespace).Get(tc.name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(secret.GetAnnotations(), tc.annotations) {
+			t.Errorf("%s/%s: expected annotations %v, got %v", tc.namespace, tc.name, tc.annotations, secret.GetAnnotations())
+		}
+		if secret.GetLabels()[BindingSecretLabel] != "true" {
+			t.Errorf("%s/%s: expected label %s, got %v", tc.namespace, tc.name, BindingSecretLabel, secret.GetLabels())
+		}
+	}
+}
+
+func TestRunWithUnavailableAPI(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed))
+	serveFromService(t, f, false)
+	for _, resource := range []string{"serviceinstances", "servicebindings"} {
+		f.dynamic.PrependReactor("list", resource, unavailableOn("list", resource))
+	}
+
+	r := New(f.clients(), Options{RetryBackoff: testBackoff})
+	if code := r.Run(); code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d", ExitSuccess, code)
+	}
+	if expected := allTargets(false); !reflect.DeepEqual(remaining(t, f), expected) {
+		t.Errorf("expected remaining objects %v, got %v", expected, remaining(t, f))
+	}
+}
+
+func TestAnnotateBindingSecretsUnavailableAPI(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed), newServiceBinding("app", "db-binding", "db-secret"))
+	serveFromService(t, f, true)
+	f.dynamic.PrependReactor("list", "servicebindings", unavailableOn("list", "servicebindings"))
+
+	options := Options{RetryBackoff: testBackoff, Force: true, Steps: []string{"annotate-binding-secrets", "delete-apiservices", "delete-rbac"}}
+	r := New(f.clients(), options)
+	if code := r.Run(); code != ExitPartialFailure {
+		t.Fatalf("expected exit code %d, got %d", ExitPartialFailure, code)
+	}
+	if outcome := stepOutcomes(r)["annotate-binding-secrets"]; outcome != outcomeFailed {
+		t.Errorf("expected annotate-binding-secrets to fail, got %s", outcome)
+	}
+	if got := remaining(t, f); !got["APIService"] || !got["ClusterRole"] {
+		t.Errorf("expected no delete step to run after annotate-binding-secrets failed, remaining %v", got)
+	}
+}
+
+func TestPreserveBindingSecretsUnavailableAPI(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		serviceExists bool
+		expectedCode  int
+		expectRemove  bool
+	}{
+		{name: "backend still exists", serviceExists: true, expectedCode: ExitPartialFailure},
+		{name: "backend service gone", expectedCode: ExitSuccess, expectRemove: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFakeClients(managementState(operatorapiv1.Removed))
+			serveFromService(t, f, tc.serviceExists)
+			f.dynamic.PrependReactor("list", "servicebindings", unavailableOn("list", "servicebindings"))
+
+			options := Options{RetryBackoff: testBackoff, Force: true, Steps: []string{"preserve-binding-secrets", "delete-apiservices", "delete-rbac"}}
+			if code := New(f.clients(), options).Run(); code != tc.expectedCode {
+				t.Fatalf("expected exit code %d, got %d", tc.expectedCode, code)
+			}
+			got := remaining(t, f)
+			if got["APIService"] == tc.expectRemove || got["ClusterRole"] == tc.expectRemove {
+				t.Errorf("expected the delete steps to run: %v, remaining %v", tc.expectRemove, got)
+			}
+		})
+	}
+}
+
+// unavailableDiscovery fails the discovery of the Service Catalog group like an
+// aggregated API server that does not respond.
+type unavailableDiscovery struct {
+	*fakediscovery.FakeDiscovery
+}
+
+func (d unavailableDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	if strings.HasPrefix(groupVersion, serviceCatalogGroup+"/") {
+		return nil, apierrors.NewServiceUnavailable("the server is currently unable to handle the request")
+	}
+	return d.FakeDiscovery.ServerResourcesForGroupVersion(groupVersion)
+}
+
+// kubeWithDiscovery replaces the discovery client of a clientset.
+type kubeWithDiscovery struct {
+	kubernetes.Interface
+	discovery discovery.DiscoveryInterface
+}
+
+func (k kubeWithDiscovery) Discovery() discovery.DiscoveryInterface {
+	return k.discovery
+}
+
+func TestBackupUnavailableAPI(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		serviceExists bool
+		expectedCode  int
+		expectRemove  bool
+	}{
+		{name: "backend still exists", serviceExists: true, expectedCode: ExitPartialFailure},
+		{name: "backend service gone", expectedCode: ExitSuccess, expectRemove: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFakeClients(managementState(operatorapiv1.Removed))
+			serveServiceCatalog(f)
+			serveFromService(t, f, tc.serviceExists)
+			clients := f.clients()
+			clients.Kube = kubeWithDiscovery{Interface: f.kube, discovery: unavailableDiscovery{f.kube.Discovery().(*fakediscovery.FakeDiscovery)}}
+
+			r := New(clients, Options{RetryBackoff: testBackoff})
+			if code := r.Run(); code != tc.expectedCode {
+				t.Fatalf("expected exit code %d, got %d", tc.expectedCode, code)
+			}
+			if expected := allTargets(!tc.expectRemove); !reflect.DeepEqual(remaining(t, f), expected) {
+				t.Errorf("expected remaining objects %v, got %v", expected, remaining(t, f))
+			}
+		})
+	}
+}
+
+func TestBackup(t *testing.T) {
+	t.Run("configmap", func(t *testing.T) {
+		f := newFakeClients(managementState(operatorapiv1.Removed), newServiceBinding("app", "db-binding", "db-secret"))
+		serveServiceCatalog(f)
+		if code := New(f.clients(), Options{RetryBackoff: testBackoff, Force: true}).Run(); code != ExitSuccess {
+			t.Fatalf("expected exit code %d, got %d", ExitSuccess, code)
+		}
+
+		configMaps, err := f.kube.CoreV1().ConfigMaps(DefaultRemoverNamespace).List(metav1.ListOptions{LabelSelector: backupLabel})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(configMaps.Items) != 1 {
+			t.Fatalf("expected 1 backup ConfigMap, got %d", len(configMaps.Items))
+		}
+		if data := configMaps.Items[0].Data["backup.yaml"]; !strings.Contains(data, "name: db-binding") {
+			t.Errorf("expected the backup to contain the ServiceBinding, got %q", data)
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "svcat-backup")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "backup.json")
+
+		f := newFakeClients(managementState(operatorapiv1.Removed), newServiceBinding("app", "db-binding", "db-secret"))
+		serveServiceCatalog(f)
+		options := Options{RetryBackoff: testBackoff, BackupTo: BackupToFile, BackupPath: path, BackupFormat: BackupFormatJSON, Force: true}
+		if code := New(f.clients(), options).Run(); code != ExitSuccess {
+			t.Fatalf("expected exit code %d, got %d", ExitSuccess, code)
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), `"name":"db-binding"`) {
+			t.Errorf("expected the backup to contain the ServiceBinding, got %q", data)
+		}
+	})
+}
+
+func TestInventory(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed),
+		newServiceBinding("app", "db-binding", "db-secret"),
+		newBindingSecret("app", "db-secret", "db-binding"),
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "servicecatalog.k8s.io/v1beta1",
+			"kind":       "ServiceInstance",
+			"metadata":   map[string]interface{}{"namespace": "app", "name": "db"},
+		}},
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "servicecatalog.k8s.io/v1beta1",
+			"kind":       "ClusterServiceBroker",
+			"metadata":   map[string]interface{}{"name": "template-service-broker"},
+			"spec":       map[string]interface{}{"url": "https://broker.example.com"},
+		}},
+	)
+	for _, pod := range []*corev1.Pod{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: "web"},
+			Spec: corev1.PodSpec{Volumes: []corev1.Volume{{
+				Name:         "credentials",
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "db-secret"}},
+			}}},
+		},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: "worker"}},
+	} {
+		if err := f.kube.Tracker().Add(pod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inventory, err := New(f.clients(), Options{RetryBackoff: testBackoff}).Inventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Inventory{
+		Namespaces:      []NamespaceUsage{{Namespace: "app", ServiceInstances: 1, ServiceBindings: 1}},
+		OwnedSecrets:    []OwnedSecret{{Namespace: "app", Name: "db-secret", OwnerKind: "ServiceBinding", OwnerName: "db-binding"}},
+		Brokers:         []Broker{{Kind: "ClusterServiceBroker", Name: "template-service-broker", URL: "https://broker.example.com"}},
+		SecretConsumers: []SecretConsumer{{Namespace: "app", Name: "web", Secrets: []string{"db-secret"}}},
+	}
+	if !reflect.DeepEqual(inventory, expected) {
+		t.Errorf("expected inventory %+v, got %+v", expected, inventory)
+	}
+}
+
+func TestChunkDocuments(t *testing.T) {
+	documents := [][]byte{[]byte("aaaa"), []byte("bb"), []byte("cccccccc"), []byte("d")}
+	chunks := chunkDocuments(documents, 6)
+	expected := []string{"aaaabb", "cccccccc", "d"}
+	if len(chunks) != len(expected) {
+		t.Fatalf("expected %d chunks, got %d", len(expected), len(chunks))
+	}
+	for i := range expected {
+		if string(chunks[i]) != expected[i] {
+			t.Errorf("chunk %d: expected %q, got %q", i, expected[i], chunks[i])
+		}
+	}
+}
+
+func TestCheckLiveResources(t *testing.T) {
+	tests := []struct {
+		name         string
+		force        bool
+		annotate     bool
+		expectedCode int
+		expectRemove bool
+	}{
+		{
+			name:         "live resources block the teardown",
+			expectedCode: ExitLiveResources,
+		},
+		{
+			name:         "forced with the flag",
+			force:        true,
+			expectedCode: ExitSuccess,
+			expectRemove: true,
+		},
+		{
+			name:         "forced with the annotation",
+			annotate:     true,
+			expectedCode: ExitSuccess,
+			expectRemove: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFakeClients(managementState(operatorapiv1.Removed), newServiceBinding("app", "db-binding", "db-secret"))
+			if tc.annotate {
+				cr, err := f.operator.OperatorV1().ServiceCatalogAPIServers().Get(DefaultCustomResourceName, metav1.GetOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				cr.Annotations = map[string]string{ForceRemovalAnnotation: "true"}
+				if _, err := f.operator.OperatorV1().ServiceCatalogAPIServers().Update(cr); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			code := New(f.clients(), Options{RetryBackoff: testBackoff, Force: tc.force}).Run()
+			if code != tc.expectedCode {
+				t.Errorf("expected exit code %d, got %d", tc.expectedCode, code)
+			}
+			if expected := allTargets(!tc.expectRemove); !reflect.DeepEqual(remaining(t, f), expected) {
+				t.Errorf("expected remaining objects %v, got %v", expected, remaining(t, f))
+			}
+			if code == ExitLiveResources {
+				expectCondition(t, f, configv1.OperatorDegraded, configv1.ConditionTrue)
+			}
+		})
+	}
+}
+
+func TestCheckLiveResourcesUnavailableAPI(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      int
+		serviceExists bool
+		expectedCode  int
+		expectProceed bool
+	}{
+		{
+			name:          "unavailable for a while",
+			failures:      2,
+			serviceExists: true,
+			expectedCode:  ExitLiveResources,
+		},
+		{
+			name:          "unavailable with a live backend",
+			failures:      100,
+			serviceExists: true,
+			expectedCode:  ExitPartialFailure,
+		},
+		{
+			name:          "backend service gone",
+			failures:      100,
+			expectedCode:  ExitSuccess,
+			expectProceed: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFakeClients(managementState(operatorapiv1.Removed), newServiceBinding("app", "db-binding", "db-secret"))
+			serveFromService(t, f, tc.serviceExists)
+			f.dynamic.PrependReactor("list", "serviceinstances", unavailableTimes("list", "serviceinstances", tc.failures))
+			f.dynamic.PrependReactor("list", "servicebindings", unavailableTimes("list", "servicebindings", tc.failures))
+
+			r := New(f.clients(), Options{RetryBackoff: testBackoff})
+			code, ok := r.checkLiveResources(nil)
+			if code != tc.expectedCode || ok != tc.expectProceed {
+				t.Errorf("expected exit code %d and proceed=%v, got %d and %v", tc.expectedCode, tc.expectProceed, code, ok)
+			}
+		})
+	}
+}
+
+func TestControllerManager(t *testing.T) {
+	tests := []struct {
+		name            string
+		state           *operatorapiv1.ManagementState
+		namespaceExists bool
+		expectedCode    int
+		expectRemove    bool
+	}{
+		{
+			name:         "controller manager already removed",
+			expectedCode: ExitSuccess,
+			expectRemove: true,
+		},
+		{
+			name:            "managed controller manager stops the teardown",
+			state:           managementState(operatorapiv1.Managed),
+			namespaceExists: true,
+			expectedCode:    ExitPartialFailure,
+		},
+		{
+			name:         "removed controller manager namespace is gone",
+			state:        managementState(operatorapiv1.Removed),
+			expectedCode: ExitSuccess,
+			expectRemove: true,
+		},
+		{
+			name:            "controller manager is not removed in time",
+			state:           managementState(operatorapiv1.Unmanaged),
+			namespaceExists: true,
+			expectedCode:    ExitPartialFailure,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFakeClients(managementState(operatorapiv1.Removed))
+			if tc.state != nil {
+				err := f.operator.Tracker().Add(&operatorapiv1.ServiceCatalogControllerManager{
+					ObjectMeta: metav1.ObjectMeta{Name: DefaultControllerManagerName},
+					Spec: operatorapiv1.ServiceCatalogControllerManagerSpec{
+						OperatorSpec: operatorapiv1.OperatorSpec{ManagementState: *tc.state},
+					},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.namespaceExists {
+				if err := f.kube.Tracker().Add(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: DefaultControllerManagerNamespace}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			options := Options{RetryBackoff: testBackoff, ControllerManagerTimeout: 10 * time.Millisecond}
+			if code := New(f.clients(), options).Run(); code != tc.expectedCode {
+				t.Errorf("expected exit code %d, got %d", tc.expectedCode, code)
+			}
+			if expected := allTargets(!tc.expectRemove); !reflect.DeepEqual(remaining(t, f), expected) {
+				t.Errorf("expected remaining objects %v, got %v", expected, remaining(t, f))
+			}
+		})
+	}
+}
+
+func newClusterObject(apiVersion, kind, name string, labels map[string]interface{}) *unstructured.Unstructured {
+	metadata := map[string]interface{}{"name": name}
+	if labels != nil {
+		metadata["labels"] = labels
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   metadata,
+	}}
+}
+
+func TestLegacyClusterResources(t *testing.T) {
+	crd := func(name, group string) *unstructured.Unstructured {
+		obj := newClusterObject("apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", name, nil)
+		obj.Object["spec"] = map[string]interface{}{"group": group}
+		return obj
+	}
+	f := newFakeClients(managementState(operatorapiv1.Removed),
+		newClusterObject("rbac.authorization.k8s.io/v1", "ClusterRole", "servicecatalog-serviceclass-viewer", nil),
+		newClusterObject("rbac.authorization.k8s.io/v1", "ClusterRole", "system:openshift:aggregate-to-admin-catalog", map[string]interface{}{"app": "service-catalog"}),
+		newClusterObject("rbac.authorization.k8s.io/v1", "ClusterRole", "admin", nil),
+		newClusterObject("rbac.authorization.k8s.io/v1", "ClusterRole", "monitoring-svcat-dashboards", map[string]interface{}{"app": "my-service-catalog-ui", "team": "svcat"}),
+		newClusterObject("rbac.authorization.k8s.io/v1", "ClusterRoleBinding", "system:openshift:operator:openshift-service-catalog-apiserver-remover", nil),
+		newClusterObject("admissionregistration.k8s.io/v1beta1", "ValidatingWebhookConfiguration", "svcat-validating-webhook", nil),
+		crd("serviceinstances.servicecatalog.k8s.io", serviceCatalogGroup),
+		crd("servicecatalogapiservers.operator.openshift.io", "operator.openshift.io"),
+	)
+	f.kube.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "rbac.authorization.k8s.io/v1",
+			APIResources: []metav1.APIResource{
+				{Name: "clusterroles", Kind: "ClusterRole", Verbs: []string{"list", "delete"}},
+				{Name: "clusterrolebindings", Kind: "ClusterRoleBinding", Verbs: []string{"list", "delete"}},
+				{Name: "roles", Kind: "Role", Namespaced: true, Verbs: []string{"list", "delete"}},
+			},
+		},
+		{
+			GroupVersion: "admissionregistration.k8s.io/v1beta1",
+			APIResources: []metav1.APIResource{
+				{Name: "validatingwebhookconfigurations", Kind: "ValidatingWebhookConfiguration", Verbs: []string{"list", "delete"}},
+				{Name: "mutatingwebhookconfigurations", Kind: "MutatingWebhookConfiguration", Verbs: []string{"list", "delete"}},
+			},
+		},
+		{
+			GroupVersion: "apiextensions.k8s.io/v1beta1",
+			APIResources: []metav1.APIResource{
+				{Name: "customresourcedefinitions", Kind: "CustomResourceDefinition", Verbs: []string{"list", "delete"}},
+			},
+		},
+	}
+
+	r := New(f.clients(), Options{RetryBackoff: testBackoff, Steps: []string{"delete-legacy-cluster-resources"}})
+	if code := r.Run(); code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d", ExitSuccess, code)
+	}
+
+	exists := func(gvr schema.GroupVersionResource, name string) bool {
+		_, err := f.dynamic.Resource(gvr).Get(name, metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+	clusterRoles := schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"}
+	clusterRoleBindings := schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterrolebindings"}
+	webhooks := schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1beta1", Resource: "validatingwebhookconfigurations"}
+	crds := schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1beta1", Resource: "customresourcedefinitions"}
+	for _, tc := range []struct {
+		gvr    schema.GroupVersionResource
+		name   string
+		exists bool
+	}{
+		{clusterRoles, "servicecatalog-serviceclass-viewer", false},
+		{clusterRoles, "system:openshift:aggregate-to-admin-catalog", false},
+		{clusterRoles, "admin", true},
+		{clusterRoles, "monitoring-svcat-dashboards", true},
+		{clusterRoleBindings, "system:openshift:operator:openshift-service-catalog-apiserver-remover", true},
+		{webhooks, "svcat-validating-webhook", false},
+		{crds, "serviceinstances.servicecatalog.k8s.io", false},
+		{crds, "servicecatalogapiservers.operator.openshift.io", true},
+	} {
+		if got := exists(tc.gvr, tc.name); got != tc.exists {
+			t.Errorf("expected %s %s present=%v, got %v", tc.gvr.Resource, tc.name, tc.exists, got)
+		}
+	}
+}
+
+func TestStripFinalizers(t *testing.T) {
+	instance := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "servicecatalog.k8s.io/v1beta1",
+		"kind":       "ServiceInstance",
+		"metadata": map[string]interface{}{
+			"namespace":  "app",
+			"name":       "db",
+			"finalizers": []interface{}{serviceCatalogFinalizer, "example.com/keep"},
+		},
+	}}
+	binding := newServiceBinding("app", "db-binding", "db-secret")
+	binding.SetFinalizers([]string{serviceCatalogFinalizer})
+
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("enabled=%v", enabled), func(t *testing.T) {
+			f := newFakeClients(managementState(operatorapiv1.Removed), instance.DeepCopy(), binding.DeepCopy())
+			options := Options{RetryBackoff: testBackoff, Force: true, StripFinalizers: enabled, Steps: []string{"strip-finalizers"}}
+			if code := New(f.clients(), options).Run(); code != ExitSuccess {
+				t.Fatalf("expected exit code %d, got %d", ExitSuccess, code)
+			}
+
+			expected := map[schema.GroupVersionResource][]string{
+				serviceInstancesResource: {serviceCatalogFinalizer, "example.com/keep"},
+				serviceBindingsResource:  {serviceCatalogFinalizer},
+			}
+			if enabled {
+				expected = map[schema.GroupVersionResource][]string{
+					serviceInstancesResource: {"example.com/keep"},
+					serviceBindingsResource:  nil,
+				}
+			}
+			for gvr, finalizers := range expected {
+				objects, err := f.dynamic.Resource(gvr).Namespace("app").List(metav1.ListOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := objects.Items[0].GetFinalizers(); len(got)+len(finalizers) > 0 && !reflect.DeepEqual(got, finalizers) {
+					t.Errorf("expected %s finalizers %v, got %v", gvr.Resource, finalizers, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed))
+	f.kube.PrependReactor("delete", "clusterroles", failOn("delete", "clusterroles"))
+	r := New(f.clients(), Options{RetryBackoff: testBackoff})
+	if code := r.Run(); code != ExitPartialFailure {
+		t.Fatalf("expected exit code %d, got %d", ExitPartialFailure, code)
+	}
+
+	dir, err := ioutil.TempDir("", "svcat-metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "remover.prom")
+	if err := r.Metrics().WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"# TYPE svcat_remover_success gauge\n",
+		`svcat_remover_success{management_state="Removed"} 0`,
+		`svcat_remover_objects_deleted_total{kind="APIService",management_state="Removed"} 1`,
+		`svcat_remover_step_failures_total{management_state="Removed",step="delete-rbac"} 1`,
+		`svcat_remover_retries_total{management_state="Removed"} 2`,
+		`svcat_remover_step_duration_seconds{management_state="Removed",step="delete-rbac"} `,
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected the metrics to contain %q, got:\n%s", expected, data)
+		}
+	}
+}
+
+func TestRenderLabels(t *testing.T) {
+	if got, expected := renderLabels(map[string]string{"step": "a", "kind": "say \"hi\"\n"}), `{kind="say \"hi\"\n",step="a"}`; got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestLease(t *testing.T) {
+	lease := func(holder string, renewed time.Time) *coordinationv1.Lease {
+		seconds := int32(60)
+		renewTime := metav1.NewMicroTime(renewed)
+		return &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Namespace: DefaultRemoverNamespace, Name: LeaseName},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &seconds,
+				RenewTime:            &renewTime,
+			},
+		}
+	}
+	tests := []struct {
+		name         string
+		lease        *coordinationv1.Lease
+		leaseWait    time.Duration
+		expectedCode int
+	}{
+		{
+			name:         "no lease",
+			expectedCode: ExitSuccess,
+		},
+		{
+			name:         "released lease",
+			lease:        lease("", time.Now()),
+			expectedCode: ExitSuccess,
+		},
+		{
+			name:         "expired lease",
+			lease:        lease("other-run", time.Now().Add(-time.Hour)),
+			expectedCode: ExitSuccess,
+		},
+		{
+			name:         "lease held by another run",
+			lease:        lease("other-run", time.Now()),
+			expectedCode: ExitLeaseHeld,
+		},
+		{
+			name:         "lease still held after waiting",
+			lease:        lease("other-run", time.Now()),
+			leaseWait:    10 * time.Millisecond,
+			expectedCode: ExitLeaseHeld,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFakeClients(managementState(operatorapiv1.Removed))
+			if tc.lease != nil {
+				if err := f.kube.Tracker().Add(tc.lease); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			options := Options{RetryBackoff: testBackoff, Identity: "this-run", LeaseWait: tc.leaseWait}
+			if code := New(f.clients(), options).Run(); code != tc.expectedCode {
+				t.Fatalf("expected exit code %d, got %d", tc.expectedCode, code)
+			}
+			if expected := allTargets(tc.expectedCode != ExitSuccess); !reflect.DeepEqual(remaining(t, f), expected) {
+				t.Errorf("expected remaining objects %v, got %v", expected, remaining(t, f))
+			}
+
+			lease, err := f.kube.CoordinationV1().Leases(DefaultRemoverNamespace).Get(LeaseName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			holder := leaseHolder(lease)
+			if tc.expectedCode == ExitSuccess && holder != "" {
+				t.Errorf("expected the lease to be released, held by %q", holder)
+			}
+			if tc.expectedCode == ExitLeaseHeld && holder != "other-run" {
+				t.Errorf("expected the lease to stay with other-run, held by %q", holder)
+			}
+		})
+	}
+}
+
+func TestLeaseTakenOverDuringRun(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed))
+	r := New(f.clients(), Options{RetryBackoff: testBackoff, Identity: "this-run", LeaseDuration: 30 * time.Millisecond})
+
+	// Another run takes the Lease while the APIServices are deleted, and the
+	// deletion waits for the renewal to notice.
+	var takenOver bool
+	f.dynamic.PrependReactor("delete", "apiservices", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if takenOver {
+			return false, nil, nil
+		}
+		takenOver = true
+		lease, err := f.kube.CoordinationV1().Leases(DefaultRemoverNamespace).Get(LeaseName, metav1.GetOptions{})
+		if err != nil {
+			return true, nil, err
+		}
+		holder, seconds, now := "other-run", int32(60), metav1.NewMicroTime(time.Now())
+		lease.Spec.HolderIdentity = &holder
+		lease.Spec.LeaseDurationSeconds = &seconds
+		lease.Spec.RenewTime = &now
+		if _, err := f.kube.CoordinationV1().Leases(DefaultRemoverNamespace).Update(lease); err != nil {
+			return true, nil, err
+		}
+		for i := 0; i < 500 && !r.stopped(); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false, nil, nil
+	})
+
+	if code := r.Run(); code != ExitLeaseHeld {
+		t.Fatalf("expected exit code %d, got %d", ExitLeaseHeld, code)
+	}
+	if !remaining(t, f)["ClusterRole"] {
+		t.Errorf("expected no step to start after the lease was lost")
+	}
+	lease, err := f.kube.CoordinationV1().Leases(DefaultRemoverNamespace).Get(LeaseName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if holder := leaseHolder(lease); holder != "other-run" {
+		t.Errorf("expected the lease to stay with other-run, held by %q", holder)
+	}
+}
+
+func TestStop(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed))
+	r := New(f.clients(), Options{RetryBackoff: testBackoff})
+	f.kube.PrependReactor("delete", "namespaces", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		// The signal arrives while the first namespace is being deleted.
+		r.Stop()
+		return false, nil, nil
+	})
+
+	if code := r.Run(); code != ExitInterrupted {
+		t.Fatalf("expected exit code %d, got %d", ExitInterrupted, code)
+	}
+	got := remaining(t, f)
+	if got["Namespace"] {
+		t.Error("expected the running step to finish removing the operator namespace")
+	}
+	if !got["OperandNamespace"] || !got["ClusterRole"] {
+		t.Errorf("expected no step to start after the stop, remaining %v", got)
+	}
+	if outcome := stepOutcomes(r)["delete-rbac"]; outcome != outcomeFailed {
+		t.Errorf("expected the steps not run to be recorded as failed, got %s", outcome)
+	}
+}
+
+func TestStepTimeout(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed))
+	f.kube.PrependReactor("delete", "namespaces", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	options := Options{RetryBackoff: testBackoff, NamespaceTimeout: time.Hour, StepTimeout: 10 * time.Millisecond, Steps: []string{"delete-operator-namespace"}}
+
+	start := time.Now()
+	if code := New(f.clients(), options).Run(); code != ExitPartialFailure {
+		t.Fatalf("expected exit code %d, got %d", ExitPartialFailure, code)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the step timeout to cut the namespace wait short, took %v", elapsed)
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	defer log.SetLevel(log.GetLevel())
+
+	tests := []struct {
+		name             string
+		logLevel         operatorapiv1.LogLevel
+		operatorLogLevel operatorapiv1.LogLevel
+		override         string
+		expected         log.Level
+	}{
+		{name: "default", expected: log.InfoLevel},
+		{name: "log level", logLevel: operatorapiv1.Debug, expected: log.DebugLevel},
+		{name: "operator log level", logLevel: operatorapiv1.Debug, operatorLogLevel: operatorapiv1.TraceAll, expected: log.TraceLevel},
+		{name: "flag override", operatorLogLevel: operatorapiv1.Debug, override: "Normal", expected: log.InfoLevel},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			log.SetLevel(log.WarnLevel)
+			f := newFakeClients(managementState(operatorapiv1.Managed))
+			cr, err := f.operator.OperatorV1().ServiceCatalogAPIServers().Get(DefaultCustomResourceName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cr.Spec.LogLevel = tc.logLevel
+			cr.Spec.OperatorLogLevel = tc.operatorLogLevel
+			if _, err := f.operator.OperatorV1().ServiceCatalogAPIServers().Update(cr); err != nil {
+				t.Fatal(err)
+			}
+
+			New(f.clients(), Options{RetryBackoff: testBackoff, LogLevel: tc.override}).Run()
+			if level := log.GetLevel(); level != tc.expected {
+				t.Errorf("expected log level %s, got %s", tc.expected, level)
+			}
+		})
+	}
+}
+
+func TestStructuredLogging(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFormatter(&log.JSONFormatter{})
+	defer log.SetOutput(os.Stderr)
+	defer log.SetFormatter(&log.TextFormatter{})
+
+	f := newFakeClients(managementState(operatorapiv1.Removed))
+	f.kube.PrependReactor("delete", "clusterroles", failOn("delete", "clusterroles"))
+	r := New(f.clients(), Options{RetryBackoff: testBackoff, Steps: []string{"delete-rbac"}})
+	r.Run()
+	r.Results().Report(false)
+
+	var stepLogged, actionLogged, resultLogged, stepReported bool
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSON log line %q: %v", line, err)
+		}
+		if entry["msg"] == "Removing" && entry["kind"] == "ClusterRole" && entry["name"] == DefaultClusterRoleName && entry["step"] == "delete-rbac" {
+			stepLogged = true
+		}
+		if entry["msg"] == "action" && entry["step"] == "delete-rbac" && entry["kind"] == "ClusterRole" && entry["outcome"] == "failed" && entry["error"] != nil && entry["duration"] != nil {
+			actionLogged = true
+		}
+		if entry["msg"] == "result" && entry["kind"] == "ClusterRole" && entry["outcome"] == "failed" && entry["error"] != nil {
+			resultLogged = true
+		}
+		if entry["msg"] == "step result" && entry["step"] == "delete-rbac" && entry["duration"] != nil && entry["error"] != nil {
+			stepReported = true
+		}
+	}
+	if !stepLogged {
+		t.Error("expected the log entries of a step to have the step field")
+	}
+	if !actionLogged {
+		t.Error("expected an action entry with step, kind, outcome, error and duration fields when the action happens")
+	}
+	if !resultLogged {
+		t.Error("expected a result entry with kind, outcome and error fields")
+	}
+	if !stepReported {
+		t.Error("expected a step result entry with step, duration and error fields")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name          string
+		errs          []error
+		expectedCalls int
+		expectErr     bool
+	}{
+		{
+			name:          "success",
+			expectedCalls: 1,
+		},
+		{
+			name: "transient errors are retried",
+			errs: []error{
+				apierrors.NewTooManyRequests("slow down", 1),
+				apierrors.NewConflict(schema.GroupResource{Resource: "secrets"}, "db-secret", fmt.Errorf("changed")),
+			},
+			expectedCalls: 3,
+		},
+		{
+			name:          "not found is not retried",
+			errs:          []error{apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "db-secret")},
+			expectedCalls: 1,
+			expectErr:     true,
+		},
+		{
+			name: "gives up when the budget is exhausted",
+			errs: []error{
+				apierrors.NewServerTimeout(schema.GroupResource{Resource: "secrets"}, "get", 1),
+				apierrors.NewInternalError(fmt.Errorf("etcd leader changed")),
+				apierrors.NewServerTimeout(schema.GroupResource{Resource: "secrets"}, "get", 1),
+			},
+			expectedCalls: 3,
+			expectErr:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := New(newFakeClients(nil).clients(), Options{RetryBackoff: testBackoff})
+			calls := 0
+			err := r.retry("test", func() error {
+				calls++
+				if calls <= len(tc.errs) {
+					return tc.errs[calls-1]
+				}
+				return nil
+			})
+			if calls != tc.expectedCalls {
+				t.Errorf("expected %d calls, got %d", tc.expectedCalls, calls)
+			}
+			if (err != nil) != tc.expectErr {
+				t.Errorf("expected error %v, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateSteps(t *testing.T) {
+	if err := ValidateSteps([]string{"delete-rbac", "delete-apiservices"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateSteps([]string{"delete-rbac", "delete-everything"}); err == nil {
+		t.Error("expected an error for an unknown step")
+	}
+}
+
+func TestScaleDownOperandWaitsForStatus(t *testing.T) {
+	defer func(interval time.Duration) { operandPollInterval = interval }(operandPollInterval)
+	operandPollInterval = time.Millisecond
+
+	// The status of the Deployment catches up with its scaled down spec a
+	// few checks later.
+	f := newFakeClients(managementState(operatorapiv1.Removed))
+	lists := 0
+	f.dynamic.PrependReactor("list", "deployments", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if lists++; lists > 2 {
+			return false, nil, nil
+		}
+		deployment := newWorkload("Deployment", DefaultOperandNamespace, "apiserver")
+		if err := unstructured.SetNestedField(deployment.Object, int64(1), "status", "replicas"); err != nil {
+			return true, nil, err
+		}
+		return true, &unstructured.UnstructuredList{Object: map[string]interface{}{}, Items: []unstructured.Unstructured{*deployment}}, nil
+	})
+
+	options := Options{RetryBackoff: testBackoff, NamespaceTimeout: 0, Steps: []string{"scale-down-operand"}}
+	if code := New(f.clients(), options).Run(); code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d", ExitSuccess, code)
+	}
+	if lists < 3 {
+		t.Errorf("expected the step to wait for the status to catch up, listed %d times", lists)
+	}
+}
+
+func TestScaleDownOperand(t *testing.T) {
+	f := newFakeClients(managementState(operatorapiv1.Removed))
+	r := New(f.clients(), Options{RetryBackoff: testBackoff, Steps: []string{"scale-down-operand"}})
+	if code := r.Run(); code != ExitSuccess {
+		t.Fatalf("expected exit code %d, got %d", ExitSuccess, code)
+	}
+
+	deployment, err := f.dynamic.Resource(deploymentsResource).Namespace(DefaultOperandNamespace).Get("apiserver", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replicas, _, _ := unstructured.NestedInt64(deployment.Object, "spec", "replicas"); replicas != 0 {
+		t.Errorf("expected the operand deployment to be scaled to 0, got %d replicas", replicas)
+	}
+	_, err = f.dynamic.Resource(daemonSetsResource).Namespace(DefaultOperandNamespace).Get("apiserver", metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected the operand daemonset to be removed, got %v", err)
+	}
+}
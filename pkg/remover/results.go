@@ -0,0 +1,175 @@
+package remover
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// outcome is the result of a single removal action or step.
+type outcome string
+
+const (
+	outcomeDeleted       outcome = "deleted"
+	outcomeUpdated       outcome = "updated"
+	outcomeAlreadyAbsent outcome = "already-absent"
+	outcomeSkipped       outcome = "skipped"
+	outcomeFailed        outcome = "failed"
+	outcomeCompleted     outcome = "completed"
+)
+
+// result records what happened to one object the remover acted on.
+type result struct {
+	kind      string
+	namespace string
+	name      string
+	outcome   outcome
+	message   string
+	duration  time.Duration
+}
+
+// stepResult records the outcome of one pipeline step.
+type stepResult struct {
+	name     string
+	outcome  outcome
+	message  string
+	duration time.Duration
+}
+
+// ResultSet aggregates the outcome of every step and removal action of a run.
+type ResultSet struct {
+	results []result
+	steps   []stepResult
+	// dryRun prefixes the logged outcomes with [dry-run].
+	dryRun bool
+}
+
+// add records the outcome of an action on an object and logs it.
+func (r *ResultSet) add(kind, namespace, name string, o outcome, message string) {
+	r.addTimed(kind, namespace, name, o, message, 0)
+}
+
+// addTimed records the outcome of an action on an object and how long it
+// took, and logs it.
+func (r *ResultSet) addTimed(kind, namespace, name string, o outcome, message string, duration time.Duration) {
+	res := result{kind: kind, namespace: namespace, name: name, outcome: o, message: message, duration: duration}
+	r.results = append(r.results, res)
+	res.log(r.dryRun, "action")
+}
+
+// addStep records the outcome of a pipeline step that did not run.
+func (r *ResultSet) addStep(name string, o outcome, message string) {
+	r.addTimedStep(name, o, message, 0)
+}
+
+// addTimedStep records the outcome of a pipeline step and how long it ran.
+func (r *ResultSet) addTimedStep(name string, o outcome, message string, duration time.Duration) {
+	r.steps = append(r.steps, stepResult{name: name, outcome: o, message: message, duration: duration})
+}
+
+// recordDelete classifies the error returned by a Delete call and records it
+// with how long the deletion took.
+func (r *ResultSet) recordDelete(kind, namespace, name string, err error, duration time.Duration) {
+	switch {
+	case err == nil:
+		r.addTimed(kind, namespace, name, outcomeDeleted, "", duration)
+	case apierrors.IsNotFound(err):
+		r.addTimed(kind, namespace, name, outcomeAlreadyAbsent, "", duration)
+	default:
+		r.addTimed(kind, namespace, name, outcomeFailed, err.Error(), duration)
+	}
+}
+
+// Failed returns true if any recorded action or step failed.
+func (r *ResultSet) Failed() bool {
+	for _, res := range r.results {
+		if res.outcome == outcomeFailed {
+			return true
+		}
+	}
+	for _, res := range r.steps {
+		if res.outcome == outcomeFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// ExitCode returns the exit code for the run based on the recorded results.
+func (r *ResultSet) ExitCode() int {
+	if r.Failed() {
+		return ExitPartialFailure
+	}
+	return ExitSuccess
+}
+
+// Report logs one line per step and recorded action followed by the totals
+// per outcome.
+func (r *ResultSet) Report(dryRun bool) {
+	prefix := ""
+	if dryRun {
+		prefix = "[dry-run] "
+	}
+
+	for _, res := range r.steps {
+		fields := log.Fields{"step": res.name, "outcome": res.outcome}
+		if res.duration > 0 {
+			fields["duration"] = res.duration.Seconds()
+		}
+		addMessage(fields, res.outcome, res.message)
+		if res.outcome == outcomeFailed {
+			log.WithFields(fields).Errorf("%sstep result", prefix)
+		} else {
+			log.WithFields(fields).Infof("%sstep result", prefix)
+		}
+	}
+
+	counts := map[outcome]int{}
+	for _, res := range r.results {
+		counts[res.outcome]++
+		res.log(dryRun, "result")
+	}
+	log.Infof("%sSummary: %d deleted, %d updated, %d already absent, %d skipped, %d failed", prefix,
+		counts[outcomeDeleted], counts[outcomeUpdated], counts[outcomeAlreadyAbsent], counts[outcomeSkipped], counts[outcomeFailed])
+}
+
+// log logs the outcome of the action with the fields of the object.
+func (res result) log(dryRun bool, message string) {
+	fields := objectFields(res.kind, res.namespace, res.name)
+	fields["outcome"] = res.outcome
+	if res.duration > 0 {
+		fields["duration"] = res.duration.Seconds()
+	}
+	addMessage(fields, res.outcome, res.message)
+	if dryRun {
+		message = "[dry-run] " + message
+	}
+	if res.outcome == outcomeFailed {
+		log.WithFields(fields).Error(message)
+	} else {
+		log.WithFields(fields).Info(message)
+	}
+}
+
+// objectFields returns the log fields identifying an object. The namespace is
+// left out for cluster scoped objects.
+func objectFields(kind, namespace, name string) log.Fields {
+	fields := log.Fields{"kind": kind, "name": name}
+	if namespace != "" {
+		fields["namespace"] = namespace
+	}
+	return fields
+}
+
+// addMessage adds the message of a result to the log fields, as the error of
+// a failure.
+func addMessage(fields log.Fields, o outcome, message string) {
+	switch {
+	case message == "":
+	case o == outcomeFailed:
+		fields["error"] = message
+	default:
+		fields["message"] = message
+	}
+}
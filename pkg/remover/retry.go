@@ -0,0 +1,65 @@
+package remover
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// DefaultRetryBackoff is the backoff applied to API calls failing with a
+// transient error. It makes up to five attempts, sleeping between 7.5 and 11
+// seconds in total before giving up.
+var DefaultRetryBackoff = wait.Backoff{
+	Duration: 500 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.5,
+	Steps:    5,
+}
+
+// isRetryable returns true for errors that are expected to go away on their
+// own, such as throttling, conflicts or the apiserver restarting during an
+// upgrade.
+func isRetryable(err error) bool {
+	return apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsConflict(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		utilnet.IsConnectionRefused(err)
+}
+
+// retry calls fn until it succeeds, fails with an error that is not
+// retryable, or the retry budget is exhausted. fn must redo any read its
+// request depends on, since a conflict means the object changed.
+func (r *Remover) retry(description string, fn func() error) error {
+	var lastErr error
+	attempts := 0
+	err := wait.ExponentialBackoff(r.options.RetryBackoff, func() (bool, error) {
+		attempts++
+		lastErr = fn()
+		if lastErr == nil || !isRetryable(lastErr) {
+			return true, nil
+		}
+		log.WithFields(log.Fields{"attempt": attempts, "error": lastErr.Error()}).Warningf("%s failed with a transient error, retrying", description)
+		return false, nil
+	})
+	if attempts > 1 {
+		r.metrics.add(metricRetries, nil, float64(attempts-1))
+	}
+	if err == wait.ErrWaitTimeout {
+		// Keep the status of API errors so callers can still tell why the
+		// request failed.
+		if status, ok := lastErr.(apierrors.APIStatus); ok {
+			s := status.Status()
+			s.Message = fmt.Sprintf("%s: giving up after %d attempts: %s", description, attempts, s.Message)
+			return &apierrors.StatusError{ErrStatus: s}
+		}
+		return fmt.Errorf("%s: giving up after %d attempts: %v", description, attempts, lastErr)
+	}
+	return lastErr
+}
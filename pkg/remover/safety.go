@@ -0,0 +1,70 @@
+package remover
+
+import (
+	"fmt"
+
+	operatorapiv1 "github.com/openshift/api/operator/v1"
+	log "github.com/sirupsen/logrus"
+)
+
+// ForceRemovalAnnotation on the ServiceCatalogAPIServer CR set to "true" lets
+// the teardown run while ServiceInstances or ServiceBindings still exist.
+const ForceRemovalAnnotation = "servicecatalog.openshift.io/force-removal"
+
+// checkLiveResources refuses the teardown while ServiceInstances or
+// ServiceBindings exist, unless forced with Options.Force or
+// ForceRemovalAnnotation on the CR. The CR is nil if it does not exist. A
+// Service Catalog API whose APIServices point at services that no longer exist
+// cannot hold live resources and does not block the teardown, but an API that
+// is only unavailable for a while does. It returns false and the exit code of the run if the teardown must not start.
+func (r *Remover) checkLiveResources(operatorConfig *operatorapiv1.ServiceCatalogAPIServer) (int, bool) {
+	if r.options.Force {
+		log.Warning("Removal is forced, not checking for live ServiceInstances and ServiceBindings")
+		return ExitSuccess, true
+	}
+	if operatorConfig != nil && operatorConfig.Annotations[ForceRemovalAnnotation] == "true" {
+		log.Warningf("ServiceCatalogAPIServer is annotated %s=true, not checking for live ServiceInstances and ServiceBindings", ForceRemovalAnnotation)
+		return ExitSuccess, true
+	}
+
+	instances, err := r.listAll(serviceInstancesResource)
+	if r.serviceCatalogAPIGone(err) {
+		log.Warningf("Service Catalog API is not served by any service, no ServiceInstances or ServiceBindings can be live: %v", err)
+		return ExitSuccess, true
+	} else if err != nil {
+		return r.liveResourcesCheckFailed(err), false
+	}
+	bindings, err := r.listAll(serviceBindingsResource)
+	if r.serviceCatalogAPIGone(err) {
+		log.Warningf("Service Catalog API is not served by any service, no ServiceInstances or ServiceBindings can be live: %v", err)
+		return ExitSuccess, true
+	} else if err != nil {
+		return r.liveResourcesCheckFailed(err), false
+	}
+	if len(instances)+len(bindings) == 0 {
+		return ExitSuccess, true
+	}
+
+	for _, usage := range countByNamespace(instances, bindings) {
+		log.WithFields(log.Fields{
+			"namespace":        usage.Namespace,
+			"serviceInstances": usage.ServiceInstances,
+			"serviceBindings":  usage.ServiceBindings,
+		}).Warning("Live Service Catalog resources")
+	}
+	message := fmt.Sprintf("%d ServiceInstance(s) and %d ServiceBinding(s) still exist, run the inventory subcommand for details, then rerun with --force or annotate the ServiceCatalogAPIServer CR with %s=true",
+		len(instances), len(bindings), ForceRemovalAnnotation)
+	log.Errorf("Refusing to remove Service Catalog: %s", message)
+	r.results.addStep("check-live-resources", outcomeFailed, message)
+	(&clusterOperatorStatus{r: r}).degraded(reasonLiveResources, fmt.Sprintf("Refusing to remove Service Catalog: %s", message))
+	return ExitLiveResources, false
+}
+
+// liveResourcesCheckFailed records that the live resources could not be
+// counted and returns the exit code of the run.
+func (r *Remover) liveResourcesCheckFailed(err error) int {
+	log.Errorf("problem checking for live ServiceInstances and ServiceBindings, error %v", err)
+	r.results.addStep("check-live-resources", outcomeFailed, err.Error())
+	(&clusterOperatorStatus{r: r}).degraded(reasonLiveResourcesUnknown, fmt.Sprintf("Could not check for live ServiceInstances and ServiceBindings: %v", err))
+	return ExitPartialFailure
+}
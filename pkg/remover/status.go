@@ -0,0 +1,136 @@
+package remover
+
+import (
+	"fmt"
+
+	configv1 "github.com/openshift/api/config/v1"
+	log "github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reasons set on the ClusterOperator conditions.
+const (
+	reasonRemoving        = "Removing"
+	reasonAsExpected      = "AsExpected"
+	reasonStepFailed      = "StepFailed"
+	reasonRemovalFailed   = "RemovalFailed"
+	reasonRemovalComplete = "RemovalComplete"
+	// Reasons of runs that stop before any teardown step.
+	reasonLiveResources          = "LiveResources"
+	reasonLiveResourcesUnknown   = "LiveResourcesCheckFailed"
+	reasonCustomResourceError    = "CustomResourceUnreadable"
+	reasonUnknownManagementState = "UnknownManagementState"
+)
+
+// clusterOperatorStatus reports the progress of the teardown through the
+// conditions of the ClusterOperator, so it shows in oc get clusteroperators
+// and must-gather until the ClusterOperator is removed by the last step.
+type clusterOperatorStatus struct {
+	r *Remover
+}
+
+// relatedObjects returns the objects the remover acts on.
+func (s *clusterOperatorStatus) relatedObjects() []configv1.ObjectReference {
+	return []configv1.ObjectReference{
+		{Group: "operator.openshift.io", Resource: "servicecatalogapiservers", Name: s.r.options.CustomResourceName},
+		{Resource: "namespaces", Name: s.r.options.Namespace},
+		{Resource: "namespaces", Name: s.r.options.OperandNamespace},
+		{Resource: "namespaces", Name: s.r.options.LegacyNamespace},
+		{Resource: "namespaces", Name: s.r.options.RemoverNamespace},
+		{Group: apiServicesResource.Group, Resource: apiServicesResource.Resource, Name: "v1beta1." + serviceCatalogGroup},
+		{Group: "rbac.authorization.k8s.io", Resource: "clusterroles", Name: s.r.options.ClusterRoleName},
+		{Group: "rbac.authorization.k8s.io", Resource: "clusterrolebindings", Name: s.r.options.ClusterRoleName},
+	}
+}
+
+// start marks the ClusterOperator as progressing and not degraded.
+func (s *clusterOperatorStatus) start() {
+	s.update(func(status *configv1.ClusterOperatorStatus) {
+		status.RelatedObjects = s.relatedObjects()
+		setCondition(status, configv1.OperatorProgressing, configv1.ConditionTrue, reasonRemoving, "Removing the Service Catalog API server")
+		setCondition(status, configv1.OperatorDegraded, configv1.ConditionFalse, reasonAsExpected, "")
+	})
+}
+
+func (s *clusterOperatorStatus) StepStarted(name string) {
+	s.update(func(status *configv1.ClusterOperatorStatus) {
+		setCondition(status, configv1.OperatorProgressing, configv1.ConditionTrue, reasonRemoving, fmt.Sprintf("Running step %s", name))
+	})
+}
+
+func (s *clusterOperatorStatus) StepFinished(name string, o outcome, message string) {
+	if o != outcomeFailed {
+		return
+	}
+	s.update(func(status *configv1.ClusterOperatorStatus) {
+		setCondition(status, configv1.OperatorDegraded, configv1.ConditionTrue, reasonStepFailed, fmt.Sprintf("Step %s failed: %s", name, message))
+	})
+}
+
+// finish marks the ClusterOperator as no longer progressing, keeping it
+// degraded if any step failed.
+func (s *clusterOperatorStatus) finish(failed bool) {
+	s.update(func(status *configv1.ClusterOperatorStatus) {
+		if failed {
+			setCondition(status, configv1.OperatorProgressing, configv1.ConditionFalse, reasonRemovalFailed, "Removal of the Service Catalog API server failed, see the remover job logs")
+			return
+		}
+		setCondition(status, configv1.OperatorProgressing, configv1.ConditionFalse, reasonRemovalComplete, "Removal of the Service Catalog API server completed")
+	})
+}
+
+// degraded marks the ClusterOperator as degraded and no longer progressing,
+// for runs that stop before any teardown step.
+func (s *clusterOperatorStatus) degraded(reason, message string) {
+	s.update(func(status *configv1.ClusterOperatorStatus) {
+		status.RelatedObjects = s.relatedObjects()
+		setCondition(status, configv1.OperatorDegraded, configv1.ConditionTrue, reason, message)
+		setCondition(status, configv1.OperatorProgressing, configv1.ConditionFalse, reason, message)
+	})
+}
+
+// update applies mutate to the ClusterOperator status. Nothing is reported in
+// dry-run mode or once the ClusterOperator is gone, and failures are only
+// logged since reporting must not stop the teardown.
+func (s *clusterOperatorStatus) update(mutate func(status *configv1.ClusterOperatorStatus)) {
+	if s.r.options.DryRun {
+		return
+	}
+	clusterOperators := s.r.clients.Config.ConfigV1().ClusterOperators()
+	err := s.r.retry("update ClusterOperator status", func() error {
+		clusterOperator, err := clusterOperators.Get(s.r.options.ClusterOperatorName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		mutate(&clusterOperator.Status)
+		_, err = clusterOperators.UpdateStatus(clusterOperator)
+		return err
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Warningf("problem updating the status of cluster operator [%s] :  %v", s.r.options.ClusterOperatorName, err)
+	}
+}
+
+// setCondition sets a condition, keeping its transition time unless the
+// status changes.
+func setCondition(status *configv1.ClusterOperatorStatus, conditionType configv1.ClusterStatusConditionType, conditionStatus configv1.ConditionStatus, reason, message string) {
+	condition := configv1.ClusterOperatorStatusCondition{
+		Type:               conditionType,
+		Status:             conditionStatus,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+	for i := range status.Conditions {
+		if status.Conditions[i].Type != conditionType {
+			continue
+		}
+		if status.Conditions[i].Status == conditionStatus {
+			condition.LastTransitionTime = status.Conditions[i].LastTransitionTime
+		}
+		status.Conditions[i] = condition
+		return
+	}
+	status.Conditions = append(status.Conditions, condition)
+}
@@ -0,0 +1,164 @@
+package remover
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Step is a single cleanup action of the remover.
+type Step interface {
+	// Name identifies the step in logs and results.
+	Name() string
+	// Precondition reports whether the step needs to run. A step that returns
+	// false is recorded as skipped.
+	Precondition() (bool, error)
+	// Execute performs the cleanup action.
+	Execute() error
+	// Verify checks that the cleanup action took effect. It is not called in
+	// dry-run mode.
+	Verify() error
+}
+
+// Required is implemented by steps whose failure must stop the pipeline, for
+// example because later steps would destroy data the step failed to protect.
+type Required interface {
+	Required() bool
+}
+
+// StepObserver is notified as the pipeline runs its steps.
+type StepObserver interface {
+	// StepStarted is called before the precondition of a step is checked.
+	StepStarted(name string)
+	// StepFinished is called with the outcome recorded for a step.
+	StepFinished(name string, o outcome, message string)
+}
+
+// Pipeline runs registered steps in order.
+type Pipeline struct {
+	steps    []Step
+	results  *ResultSet
+	dryRun   bool
+	deadline time.Time
+	stop     <-chan struct{}
+	// stepStart is when the running step started, zero between steps.
+	stepStart time.Time
+	observers []StepObserver
+}
+
+// NewPipeline returns an empty pipeline recording step outcomes in results.
+func NewPipeline(results *ResultSet, dryRun bool) *Pipeline {
+	return &Pipeline{results: results, dryRun: dryRun}
+}
+
+// SetDeadline stops the pipeline from starting steps after the deadline.
+func (p *Pipeline) SetDeadline(deadline time.Time) {
+	p.deadline = deadline
+}
+
+// SetStop stops the pipeline from starting steps once stop is closed.
+func (p *Pipeline) SetStop(stop <-chan struct{}) {
+	p.stop = stop
+}
+
+// stopped returns true once the stop channel is closed.
+func (p *Pipeline) stopped() bool {
+	select {
+	case <-p.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// AddObserver registers an observer notified of every step.
+func (p *Pipeline) AddObserver(observer StepObserver) {
+	p.observers = append(p.observers, observer)
+}
+
+// record records the outcome of a step and notifies the observers.
+func (p *Pipeline) record(name string, o outcome, message string) {
+	var duration time.Duration
+	if !p.stepStart.IsZero() {
+		duration = time.Since(p.stepStart)
+		p.stepStart = time.Time{}
+	}
+	p.results.addTimedStep(name, o, message, duration)
+	for _, observer := range p.observers {
+		observer.StepFinished(name, o, message)
+	}
+}
+
+// Register appends a step to the pipeline.
+func (p *Pipeline) Register(step Step) {
+	p.steps = append(p.steps, step)
+}
+
+// Run executes every registered step in order. A failing step does not stop
+// the pipeline unless it is Required; all failures are returned aggregated.
+func (p *Pipeline) Run() error {
+	var errs []error
+	for i, step := range p.steps {
+		if !p.deadline.IsZero() && time.Now().After(p.deadline) {
+			log.Errorf("Deadline passed, not running the remaining steps")
+			for _, remaining := range p.steps[i:] {
+				p.record(remaining.Name(), outcomeFailed, "deadline passed before the step started")
+			}
+			errs = append(errs, fmt.Errorf("deadline passed with %d step(s) not run", len(p.steps)-i))
+			break
+		}
+		if p.stopped() {
+			log.Errorf("Stopped, not running the remaining steps")
+			for _, remaining := range p.steps[i:] {
+				p.record(remaining.Name(), outcomeFailed, "stopped before the step started")
+			}
+			errs = append(errs, fmt.Errorf("stopped with %d step(s) not run", len(p.steps)-i))
+			break
+		}
+		err := p.runStep(step)
+		if err == nil {
+			continue
+		}
+		p.record(step.Name(), outcomeFailed, err.Error())
+		errs = append(errs, fmt.Errorf("step %s failed: %v", step.Name(), err))
+		if required, ok := step.(Required); ok && required.Required() {
+			log.Errorf("Required step %s failed, not running the remaining steps", step.Name())
+			for _, remaining := range p.steps[i+1:] {
+				p.record(remaining.Name(), outcomeSkipped, fmt.Sprintf("required step %s failed", step.Name()))
+			}
+			break
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// runStep runs the precondition, execution and verification of a step and
+// records it as completed or skipped. Failures are returned to the caller.
+func (p *Pipeline) runStep(step Step) error {
+	log.Infof("Running step %s", step.Name())
+	p.stepStart = time.Now()
+	for _, observer := range p.observers {
+		observer.StepStarted(step.Name())
+	}
+	run, err := step.Precondition()
+	if err != nil {
+		return fmt.Errorf("precondition: %v", err)
+	}
+	if !run {
+		log.Infof("Step %s is not needed, skipping", step.Name())
+		p.record(step.Name(), outcomeSkipped, "precondition not met")
+		return nil
+	}
+	if err := step.Execute(); err != nil {
+		return err
+	}
+	if !p.dryRun {
+		if err := step.Verify(); err != nil {
+			return fmt.Errorf("verify: %v", err)
+		}
+	}
+	p.record(step.Name(), outcomeCompleted, "")
+	return nil
+}